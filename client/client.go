@@ -1,8 +1,19 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/antoniou/go-crawler/crawl"
 	"github.com/antoniou/go-crawler/sitemap"
@@ -13,10 +24,25 @@ import (
 //Client represents a command line client
 type Client struct {
 	app *cli.App
+
+	// args are the arguments of the crawl, saved
+	// to its state directory to resume it later
+	args []string
+
+	// resumeFrom is the state directory
+	// of the crawl being resumed, if any
+	resumeFrom string
 }
 
+// argsFile is the file of the state directory
+// the arguments of the crawl are saved to
+const argsFile = "args.json"
+
 // Run starts the command line client
 func (client *Client) Run(arguments []string) error {
+	if len(arguments) > 0 {
+		client.args = arguments[1:]
+	}
 	return client.app.Run(arguments)
 }
 
@@ -34,6 +60,153 @@ func New() (client *Client) {
 			Value: "result.out",
 			Usage: "Output file",
 		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "Format of the output file: text (the tree of links between the pages) or xml (a sitemaps.org sitemap listing the pages)",
+		},
+		cli.IntFlag{
+			Name:  "concurrency",
+			Value: 4,
+			Usage: "Number of pages fetched in parallel",
+		},
+		cli.IntFlag{
+			Name:  "parsers",
+			Value: 2,
+			Usage: "Number of pages parsed in parallel",
+		},
+		cli.DurationFlag{
+			Name:  "delay",
+			Usage: "Minimum delay between two requests to the same host (e.g. 500ms)",
+		},
+		cli.Float64Flag{
+			Name:  "rps",
+			Usage: "Maximum number of requests per second to the same host",
+		},
+		cli.IntFlag{
+			Name:  "host-connections",
+			Usage: "Maximum number of concurrent connections to the same host",
+		},
+		cli.IntFlag{
+			Name:  "retries",
+			Value: 2,
+			Usage: "Number of times a failed request is retried",
+		},
+		cli.DurationFlag{
+			Name:  "retry-delay",
+			Value: 500 * time.Millisecond,
+			Usage: "Delay before the first retry, doubling with every retry",
+		},
+		cli.DurationFlag{
+			Name:  "retry-max-delay",
+			Value: 30 * time.Second,
+			Usage: "Maximum delay between two retries",
+		},
+		cli.BoolFlag{
+			Name:  "ignore-robots",
+			Usage: "Do not honour robots.txt",
+		},
+		cli.StringFlag{
+			Name:  "user-agent",
+			Value: crawl.DefaultUserAgent,
+			Usage: "User-Agent header of the requests, whose token is matched against robots.txt",
+		},
+		cli.StringFlag{
+			Name:  "scope",
+			Value: "path",
+			Usage: "Pages crawled: path (under the seed URL), host (same host) or domain (same registrable domain, including subdomains)",
+		},
+		cli.BoolFlag{
+			Name:  "any-scheme",
+			Usage: "Crawl both http and https pages in scope",
+		},
+		cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "Only crawl URLs matching this regular expression (can be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Do not crawl URLs matching this regular expression (can be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "strip-param",
+			Usage: "Query parameter removed from URLs, on top of the tracking and session parameters (can be repeated, a trailing * matches any suffix)",
+		},
+		cli.BoolFlag{
+			Name:  "remove-trailing-slash",
+			Usage: "Treat URLs with and without a trailing slash as the same page",
+		},
+		cli.IntFlag{
+			Name:  "max-depth",
+			Usage: "Maximum number of clicks from the seed URL to the pages crawled",
+		},
+		cli.IntFlag{
+			Name:  "max-pages",
+			Usage: "Maximum number of pages crawled",
+		},
+		cli.Int64Flag{
+			Name:  "max-bytes",
+			Usage: "Stop the crawl once the pages crawled add up to this number of bytes",
+		},
+		cli.StringFlag{
+			Name:  "crawl-links",
+			Value: "navigation,frame",
+			Usage: "Comma-separated kinds of links crawled, among navigation, stylesheet, script, image, media, frame, form and resource",
+		},
+		cli.StringFlag{
+			Name:  "record-links",
+			Usage: "Comma-separated kinds of links added to the sitemap without being crawled",
+		},
+		cli.StringFlag{
+			Name:  "robots-directives",
+			Value: "respect",
+			Usage: "What is done with nofollow links and robots meta tags and headers: respect (nofollow links are not crawled and noindex pages are left out of the export), record (crawl as if there were none, but mark them in the export) or ignore",
+		},
+		cli.StringFlag{
+			Name:  "page-fields",
+			Usage: "Comma-separated fields of the pages exported after their URL, among status, content-type, content-length, response-time, title, description, h1, h2, language, last-modified and error",
+		},
+		cli.Int64Flag{
+			Name:  "max-body-size",
+			Value: crawl.DefaultMaxBodySize,
+			Usage: "Size in bytes pages are cut at, 0 for no limit",
+		},
+		cli.BoolFlag{
+			Name:  "skip-large",
+			Usage: "Fail the pages larger than --max-body-size rather than cut them",
+		},
+		cli.DurationFlag{
+			Name:  "max-duration",
+			Usage: "Stop the crawl after this long (e.g. 10m)",
+		},
+		cli.StringFlag{
+			Name:  "seen-set",
+			Value: "memory",
+			Usage: "How crawled URLs are remembered: memory (exact), bloom (scalable bloom filter) or disk (exact, on disk)",
+		},
+		cli.Float64Flag{
+			Name:  "bloom-fp-rate",
+			Value: 0.001,
+			Usage: "Target false-positive rate of --seen-set bloom",
+		},
+		cli.StringFlag{
+			Name:  "seen-dir",
+			Usage: "Directory of --seen-set disk (default: a temporary directory)",
+		},
+		cli.DurationFlag{
+			Name:  "grace-period",
+			Value: crawl.DefaultGracePeriod,
+			Usage: "Time given to the pages being crawled to be done once the crawl is interrupted",
+		},
+		cli.StringFlag{
+			Name:  "state-dir",
+			Usage: "Directory the crawl is checkpointed to, for it to be resumed with the resume command",
+		},
+		cli.DurationFlag{
+			Name:  "checkpoint-interval",
+			Value: time.Minute,
+			Usage: "Time between two checkpoints of the crawl to --state-dir",
+		},
 		cli.BoolFlag{
 			Name:  "verbose",
 			Usage: "Verbose mode",
@@ -44,12 +217,21 @@ func New() (client *Client) {
 		// Logger with verbose logging enabled/disabled
 		_ = util.Logger(c.Bool("verbose"))
 		if len(c.Args()) == 0 {
-			client.app.Commands[0].Run(c)
+			cli.ShowAppHelp(c)
 			return fmt.Errorf("Expects at least one argument")
 		}
 		return client.crawl(c)
 	}
 
+	app.Commands = []cli.Command{
+		{
+			Name:      "resume",
+			Usage:     "Resume an interrupted crawl from its last checkpoint",
+			ArgsUsage: "<state-dir>",
+			Action:    client.resume,
+		},
+	}
+
 	client.app = app
 	return client
 }
@@ -64,24 +246,330 @@ func (client *Client) crawl(c *cli.Context) error {
 		return err
 	}
 
-	crawler := crawl.NewAsyncHTTPCrawler(seedURL)
-	stmp, err := crawler.Crawl()
+	scope, err := crawlScope(c, seedURL)
+	if err != nil {
+		return err
+	}
+
+	stateDir := c.String("state-dir")
+	if client.resumeFrom != "" {
+		stateDir = client.resumeFrom
+	} else if stateDir != "" {
+		if err := saveArgs(stateDir, client.args); err != nil {
+			return err
+		}
+	}
+
+	links, err := linkPolicy(c)
+	if err != nil {
+		return err
+	}
+	directives, err := crawl.ParseDirectivePolicy(c.String("robots-directives"))
+	if err != nil {
+		return err
+	}
+	fields, err := pageFields(c)
+	if err != nil {
+		return err
+	}
+	format := c.String("format")
+	if format != "text" && format != "xml" {
+		return fmt.Errorf("Unknown output format %q", format)
+	}
+
+	seen, cleanup, err := seenSet(c, stateDir, client.resumeFrom != "")
 	if err != nil {
 		return err
 	}
+	defer cleanup()
+
+	crawler := crawl.NewCrawler(
+		crawl.WithFetchers(c.Int("concurrency")),
+		crawl.WithParsers(c.Int("parsers")),
+		crawl.WithSeenSet(seen),
+		crawl.WithScope(scope),
+		crawl.WithLinkPolicy(links),
+		crawl.WithDirectivePolicy(directives),
+		crawl.WithMaxBodySize(c.Int64("max-body-size"), bodyLimit(c)),
+		crawl.WithUserAgent(c.String("user-agent")),
+	)
+	crawler.SetCanonicalizer(canonicalizer(c))
+	crawler.SetLimits(crawl.Limits{
+		MaxDepth:    c.Int("max-depth"),
+		MaxPages:    c.Int("max-pages"),
+		MaxBytes:    c.Int64("max-bytes"),
+		MaxDuration: c.Duration("max-duration"),
+	})
+	crawler.SetRateLimiter(rateLimiter(c))
+	crawler.SetRetryPolicy(crawl.NewRetryPolicy(c.Int("retries")+1,
+		c.Duration("retry-delay"), c.Duration("retry-max-delay")))
+	if !c.Bool("ignore-robots") {
+		crawler.RespectRobots(c.String("user-agent"))
+	}
+	if stateDir != "" {
+		crawler.SetCheckpoint(stateDir, c.Duration("checkpoint-interval"))
+	}
+	if client.resumeFrom != "" {
+		if err := crawler.Resume(client.resumeFrom); err != nil {
+			return err
+		}
+	}
+	crawler.SetGracePeriod(c.Duration("grace-period"))
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer close(signals)
+	defer signal.Stop(signals)
+	go interruptOnSignal(crawler, signals)
+
+	stmp, err := crawler.Crawl(context.Background(), seedURL)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(crawler.Report())
 
 	outfile := c.String("o")
-	return client.export(outfile, stmp)
+	return client.export(outfile, format, stmp, directives == crawl.RespectDirectives, fields)
+}
+
+// interruptOnSignal interrupts crawler on the first signal
+// received, and exits on the second one. It returns once
+// signals is closed
+func interruptOnSignal(crawler *crawl.AsyncHTTPCrawler, signals <-chan os.Signal) {
+	if _, ok := <-signals; !ok {
+		return
+	}
+	log.Printf("Stopping the crawl, interrupt again to exit now")
+	crawler.Interrupt()
+
+	if _, ok := <-signals; ok {
+		log.Printf("Exiting without exporting the sitemap")
+		os.Exit(1)
+	}
+}
+
+// resume resumes the crawl checkpointed to the state directory
+// given, with the arguments the crawl was started with
+func (client *Client) resume(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "resume")
+		return fmt.Errorf("Expects the state directory of a crawl")
+	}
+	dir := c.Args()[0]
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, argsFile))
+	if err != nil {
+		return err
+	}
+	var args []string
+	if err := json.Unmarshal(data, &args); err != nil {
+		return fmt.Errorf("Invalid arguments in %s: %v", dir, err)
+	}
+
+	client.resumeFrom = dir
+	return client.app.Run(append([]string{client.app.Name}, args...))
+}
+
+// saveArgs saves the arguments of a crawl to
+// its state directory dir, to resume it later
+func saveArgs(dir string, args []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, argsFile), data, 0644)
+}
+
+// rateLimiter creates the HostLimiter described by the
+// command line flags. The stricter of --delay and --rps
+// applies. The limiter is created even when no limit
+// is set, to honour the Crawl-delay of robots.txt
+func rateLimiter(c *cli.Context) *crawl.HostLimiter {
+	delay := c.Duration("delay")
+	if d := crawl.RequestsPerSecond(c.Float64("rps")); d > delay {
+		delay = d
+	}
+	return crawl.NewHostLimiter(delay, c.Int("host-connections"))
+}
+
+// pageFields returns the fields of the pages
+// exported, as given by --page-fields
+func pageFields(c *cli.Context) ([]sitemap.PageField, error) {
+	var fields []sitemap.PageField
+	for _, s := range strings.Split(c.String("page-fields"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		field, err := sitemap.ParsePageField(s)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// linkPolicy creates the crawl.LinkPolicy described by
+// --crawl-links and --record-links. Kinds given to both
+// are crawled
+func linkPolicy(c *cli.Context) (crawl.LinkPolicy, error) {
+	policy := crawl.LinkPolicy{}
+	for _, flag := range []struct {
+		name   string
+		action crawl.LinkAction
+	}{{"record-links", crawl.RecordLink}, {"crawl-links", crawl.CrawlLink}} {
+		for _, s := range strings.Split(c.String(flag.name), ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			kind, err := crawl.ParseLinkKind(s)
+			if err != nil {
+				return nil, err
+			}
+			policy[kind] = flag.action
+		}
+	}
+	return policy, nil
+}
+
+// bodyLimit returns what is done with the
+// pages larger than --max-body-size
+func bodyLimit(c *cli.Context) crawl.BodyLimit {
+	if c.Bool("skip-large") {
+		return crawl.AbortBody
+	}
+	return crawl.TruncateBody
+}
+
+// crawlScope creates the crawl.Scope described by
+// the command line flags, for a crawl from seed
+func crawlScope(c *cli.Context, seed *url.URL) (crawl.Scope, error) {
+	var scopes []crawl.Scope
+	if !c.Bool("any-scheme") {
+		scopes = append(scopes, crawl.SameScheme(seed))
+	}
+
+	switch c.String("scope") {
+	case "path":
+		scopes = append(scopes, crawl.SameHost(seed), crawl.PathPrefix(seed))
+	case "host":
+		scopes = append(scopes, crawl.SameHost(seed))
+	case "domain":
+		scopes = append(scopes, crawl.SameDomain(seed))
+	default:
+		return nil, fmt.Errorf("Unknown scope %q, expects path, host or domain", c.String("scope"))
+	}
+
+	include, err := compilePatterns(c.StringSlice("include"))
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compilePatterns(c.StringSlice("exclude"))
+	if err != nil {
+		return nil, err
+	}
+	if len(include) > 0 || len(exclude) > 0 {
+		scopes = append(scopes, crawl.MatchPatterns(include, exclude))
+	}
+	return crawl.AllScopes(scopes...), nil
+}
+
+// seenSet creates the crawl.SeenSet described by the command
+// line flags. A disk set is kept in the state directory of the
+// crawl, if there is one and --seen-dir is not given, and starts
+// empty unless the crawl is resumed. cleanup closes a disk set
+// and removes its temporary directory, if one was created
+func seenSet(c *cli.Context, stateDir string, resume bool) (seen crawl.SeenSet, cleanup func(), err error) {
+	cleanup = func() {}
+	switch c.String("seen-set") {
+	case "memory":
+		return crawl.NewHashSeenSet(), cleanup, nil
+	case "bloom":
+		return crawl.NewBloomSeenSet(10000, c.Float64("bloom-fp-rate")), cleanup, nil
+	case "disk":
+		dir := c.String("seen-dir")
+		if dir == "" && stateDir != "" {
+			dir = filepath.Join(stateDir, "seen")
+		}
+		temp := dir == ""
+		if temp {
+			if dir, err = ioutil.TempDir("", "go-crawler"); err != nil {
+				return nil, cleanup, err
+			}
+		}
+		var disk *crawl.DiskSeenSet
+		if resume {
+			disk, err = crawl.OpenDiskSeenSet(dir)
+		} else {
+			disk, err = crawl.NewDiskSeenSet(dir)
+		}
+		if err != nil {
+			if temp {
+				os.RemoveAll(dir)
+			}
+			return nil, cleanup, err
+		}
+		cleanup = func() {
+			disk.Close()
+			if temp {
+				os.RemoveAll(dir)
+			}
+		}
+		return disk, cleanup, nil
+	}
+	return nil, cleanup, fmt.Errorf("Unknown seen set %q, expects memory, bloom or disk", c.String("seen-set"))
 }
 
-// export sitemap stmp to new file outfile
-func (client *Client) export(outfile string, stmp sitemap.Sitemapper) error {
+// canonicalizer creates the util.Canonicalizer
+// described by the command line flags
+func canonicalizer(c *cli.Context) *util.Canonicalizer {
+	canon := util.NewCanonicalizer()
+	canon.StripParams = append(append([]string{}, util.DefaultStripParams...),
+		c.StringSlice("strip-param")...)
+	canon.RemoveTrailingSlash = c.Bool("remove-trailing-slash")
+	return canon
+}
+
+// compilePatterns compiles the regular
+// expressions given on the command line
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q: %v", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// export sitemap stmp to new file outfile in the given
+// format, with the given fields of the pages for the text
+// format, leaving out the noindex pages if excludeNoindex
+// is set
+func (client *Client) export(outfile string, format string, stmp sitemap.Sitemapper, excludeNoindex bool, fields []sitemap.PageField) error {
 	f, err := os.Create(outfile)
 	if err != nil {
 		return err
 	}
 
-	err = sitemap.NewExporter(f).Export(stmp)
+	var exporter sitemap.Exporter
+	if format == "xml" {
+		x := sitemap.NewXMLExporter(f)
+		x.SetExcludeNoindex(excludeNoindex)
+		exporter = x
+	} else {
+		t := sitemap.NewExporter(f)
+		t.SetExcludeNoindex(excludeNoindex)
+		t.SetPageFields(fields...)
+		exporter = t
+	}
+	err = exporter.Export(stmp)
 	if err != nil {
 		return err
 	}
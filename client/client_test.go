@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *ClientTestSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "client")
+}
+
+func (suite *ClientTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ClientTestSuite) TestUserAgent() {
+	var mutex sync.Mutex
+	agents := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		agents[r.URL.Path] = r.Header.Get("User-Agent")
+		mutex.Unlock()
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/about">About</a>`)
+		}
+	}))
+	defer server.Close()
+
+	out := filepath.Join(suite.dir, "sitemap.out")
+	err := New().Run([]string{"go-crawler", "--user-agent", "testbot/1.0", "-o", out, server.URL})
+	assert.NoError(suite.T(), err)
+
+	// robots.txt and the pages are requested with the user-agent given
+	assert.Equal(suite.T(), map[string]string{
+		"/robots.txt": "testbot/1.0",
+		"/":           "testbot/1.0",
+		"/about":      "testbot/1.0",
+	}, agents)
+}
+
+func (suite *ClientTestSuite) TestXMLFormat() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/about">About</a><a href="/drafts">Drafts</a>`)
+		case "/drafts":
+			w.Header().Set("X-Robots-Tag", "noindex")
+			fmt.Fprint(w, `<a href="/drafts/1">Draft</a>`)
+		}
+	}))
+	defer server.Close()
+
+	out := filepath.Join(suite.dir, "sitemap.xml")
+	err := New().Run([]string{"go-crawler", "--format", "xml", "-o", out, server.URL})
+	assert.NoError(suite.T(), err)
+
+	// noindex pages are left out of the XML sitemap as well
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(data), "<urlset")
+	assert.Contains(suite.T(), string(data), "<loc>"+server.URL+"/about</loc>")
+	assert.Contains(suite.T(), string(data), "<loc>"+server.URL+"/drafts/1</loc>")
+	assert.NotContains(suite.T(), string(data), "<loc>"+server.URL+"/drafts</loc>")
+}
+
+func (suite *ClientTestSuite) TestSeenDirReused() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/about">About</a>`)
+		}
+	}))
+	defer server.Close()
+
+	// A new crawl does not take the URLs seen by
+	// the previous one in the same directory as seen
+	seenDir := filepath.Join(suite.dir, "seen")
+	for i := 0; i < 2; i++ {
+		out := filepath.Join(suite.dir, fmt.Sprintf("sitemap%d.out", i))
+		err := New().Run([]string{"go-crawler", "--seen-set", "disk", "--seen-dir", seenDir, "-o", out, server.URL})
+		assert.NoError(suite.T(), err)
+		data, err := ioutil.ReadFile(out)
+		assert.NoError(suite.T(), err)
+		assert.Contains(suite.T(), string(data), server.URL+"/about")
+	}
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
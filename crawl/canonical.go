@@ -0,0 +1,103 @@
+package crawl
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// CanonicalProblem is what is wrong with
+// the canonical URL a page declares
+type CanonicalProblem string
+
+// Possible CanonicalProblems
+const (
+	// OffSiteCanonical: the canonical URL is
+	// out of the Scope of the crawl
+	OffSiteCanonical CanonicalProblem = "off-site"
+	// NotOKCanonical: the canonical URL was answered
+	// with a status other than 200, redirects included
+	NotOKCanonical CanonicalProblem = "non-200"
+	// ChainedCanonical: the canonical page declares
+	// a canonical URL of its own
+	ChainedCanonical CanonicalProblem = "chain"
+	// LoopingCanonical: the chain of canonical URLs
+	// from the canonical page leads back to the page
+	LoopingCanonical CanonicalProblem = "loop"
+)
+
+// CanonicalIssue is a page whose canonical URL has a Problem.
+// Status is the status code of a NotOKCanonical and Next the
+// canonical URL of the canonical page of a ChainedCanonical
+type CanonicalIssue struct {
+	Page      string
+	Canonical string
+	Problem   CanonicalProblem
+	Status    int
+	Next      string
+}
+
+func (i CanonicalIssue) String() string {
+	switch i.Problem {
+	case NotOKCanonical:
+		return fmt.Sprintf("%s -> %s: %s (%d)", i.Page, i.Canonical, i.Problem, i.Status)
+	case ChainedCanonical:
+		return fmt.Sprintf("%s -> %s: %s -> %s", i.Page, i.Canonical, i.Problem, i.Next)
+	}
+	return fmt.Sprintf("%s -> %s: %s", i.Page, i.Canonical, i.Problem)
+}
+
+// addCanonical records that page declares canonical as its
+// canonical URL. Canonical URLs in scope make page a variant
+// of the canonical page in the sitemap, provided the sitemap
+// holds that page
+func (t *AsyncHttpTracker) addCanonical(page, canonical *url.URL) {
+	if canonical.String() == page.String() {
+		return
+	}
+	t.canonicals[page.String()] = canonical
+	if t.inScope(canonical) {
+		t.sitemapper.SetCanonical(page.String(), canonical.String())
+	}
+}
+
+// checkCanonicals returns the issues with the canonical
+// URLs declared by the pages crawled, by page. Canonical
+// pages that were not crawled are given the benefit of
+// the doubt
+func (t *AsyncHttpTracker) checkCanonicals() []CanonicalIssue {
+	issues := make([]CanonicalIssue, 0)
+	for page, canonical := range t.canonicals {
+		issue := CanonicalIssue{Page: page, Canonical: canonical.String()}
+		if !t.inScope(canonical) {
+			issue.Problem = OffSiteCanonical
+		} else if status, ok := t.statuses[issue.Canonical]; ok {
+			issue.Problem, issue.Status = NotOKCanonical, status
+		} else if t.canonicalLoops(page) {
+			issue.Problem = LoopingCanonical
+		} else if next, ok := t.canonicals[issue.Canonical]; ok {
+			issue.Problem, issue.Next = ChainedCanonical, next.String()
+		} else {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Page < issues[j].Page })
+	return issues
+}
+
+// canonicalLoops returns whether following the canonical
+// URLs declared from page leads back to page
+func (t *AsyncHttpTracker) canonicalLoops(page string) bool {
+	seen := map[string]bool{page: true}
+	for next, ok := t.canonicals[page]; ok; next, ok = t.canonicals[next.String()] {
+		if next.String() == page {
+			return true
+		}
+		if seen[next.String()] {
+			return false
+		}
+		seen[next.String()] = true
+	}
+	return false
+}
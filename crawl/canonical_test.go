@@ -0,0 +1,201 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var canonicalSite = map[string]string{
+	"http://example.com": `
+		<html><body>
+		<a href="/shoes">Shoes</a>
+		<a href="/shoes-red">Red shoes</a>
+		<a href="/old">Old</a>
+		<a href="/sale">Sale</a>
+		<a href="/chain">Chain</a>
+		</body></html>`,
+	"http://example.com/shoes": `
+		<html><head><link rel="canonical" href="/shoes"></head>
+		<body><a href="/">Home</a></body></html>`,
+	"http://example.com/shoes-red": `
+		<html><head><link rel="Canonical" href="shoes"></head>
+		<body><a href="/">Home</a></body></html>`,
+	"http://example.com/old": `
+		<html><head><link rel="canonical" href="https://other.com/old"></head></html>`,
+	"http://example.com/sale": `
+		<html><head><link rel="canonical" href="/missing"></head></html>`,
+	"http://example.com/chain": `
+		<html><head><link rel="canonical" href="/shoes-red"></head></html>`,
+}
+
+type CanonicalTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *CanonicalTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+func (suite *CanonicalTestSuite) TestCanonicals() {
+	c := NewTestCrawler(&siteHTTPClient{pages: canonicalSite}, 2, 2)
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	pages := <-collected
+
+	assert.Equal(suite.T(), "http://example.com/shoes",
+		pages["http://example.com/shoes-red"].Canonical.String())
+	assert.Nil(suite.T(), pages["http://example.com"].Canonical)
+
+	// Variants in scope are aliased onto their canonical page
+	assert.Equal(suite.T(), "http://example.com/shoes", stmp.Canonical("http://example.com/shoes-red"))
+	assert.Equal(suite.T(), "http://example.com/missing", stmp.Canonical("http://example.com/sale"))
+	assert.Equal(suite.T(), "", stmp.Canonical("http://example.com/shoes"))
+	assert.Equal(suite.T(), "", stmp.Canonical("http://example.com/old"))
+
+	assert.Equal(suite.T(), []CanonicalIssue{
+		{
+			Page:      "http://example.com/chain",
+			Canonical: "http://example.com/shoes-red",
+			Problem:   ChainedCanonical,
+			Next:      "http://example.com/shoes",
+		},
+		{
+			Page:      "http://example.com/old",
+			Canonical: "https://other.com/old",
+			Problem:   OffSiteCanonical,
+		},
+		{
+			Page:      "http://example.com/sale",
+			Canonical: "http://example.com/missing",
+			Problem:   NotOKCanonical,
+			Status:    404,
+		},
+	}, c.Report().CanonicalIssues)
+	assert.Contains(suite.T(), c.Report().String(),
+		"http://example.com/sale -> http://example.com/missing: non-200 (404)")
+}
+
+// bufferCloser is a bytes.Buffer sitemaps can be exported to
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+// export returns stmp as exported by a FileExporter
+func (suite *CanonicalTestSuite) export(stmp sitemap.Sitemapper) string {
+	var buf bufferCloser
+	assert.NoError(suite.T(), sitemap.NewExporter(&buf).Export(stmp))
+	return strings.TrimSpace(buf.String())
+}
+
+func (suite *CanonicalTestSuite) TestCanonicalNotCrawled() {
+	c := NewTestCrawler(&siteHTTPClient{pages: map[string]string{
+		"http://example.com": `<a href="/a">A</a>`,
+		"http://example.com/a": `<html><head><link rel="canonical" href="/the-a"></head>
+			<body><a href="/b">B</a></body></html>`,
+	}}, 1, 1)
+	c.SetLimits(Limits{MaxDepth: 1})
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), "", stmp.Canonical("http://example.com/a"))
+	assert.Equal(suite.T(), "http://example.com\n  http://example.com/a", suite.export(stmp))
+}
+
+func (suite *CanonicalTestSuite) TestVariantLinks() {
+	c := NewTestCrawler(&siteHTTPClient{pages: map[string]string{
+		"http://example.com":       `<a href="/shoes">Shoes</a><a href="/shoes-red">Red shoes</a>`,
+		"http://example.com/shoes": `<html><body><a href="/sizes">Sizes</a></body></html>`,
+		"http://example.com/shoes-red": `<html><head><link rel="canonical" href="/shoes"></head>
+			<body><a href="/red">Red</a></body></html>`,
+		"http://example.com/sizes": `<html></html>`,
+		"http://example.com/red":   `<html></html>`,
+	}}, 1, 1)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The page only linked to by the variant is in the export
+	assert.Equal(suite.T(), "http://example.com/shoes", stmp.Canonical("http://example.com/shoes-red"))
+	assert.ElementsMatch(suite.T(), []string{"http://example.com/sizes", "http://example.com/red"},
+		*stmp.LinksFrom("http://example.com/shoes"))
+	assert.Contains(suite.T(), suite.export(stmp), "\n    http://example.com/red")
+	assert.NotContains(suite.T(), suite.export(stmp), "http://example.com/shoes-red\n")
+}
+
+func (suite *CanonicalTestSuite) TestMutualCanonicals() {
+	c := NewTestCrawler(&siteHTTPClient{pages: map[string]string{
+		"http://example.com": `<a href="/a">A</a><a href="/b">B</a>`,
+		"http://example.com/a": `<html><head><link rel="canonical" href="/b"></head>
+			<body><a href="/c">C</a></body></html>`,
+		"http://example.com/b": `<html><head><link rel="canonical" href="/a"></head>
+			<body><a href="/d">D</a></body></html>`,
+		"http://example.com/c": `<html></html>`,
+		"http://example.com/d": `<html></html>`,
+	}}, 1, 1)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// Only one of the two pages is made a variant of the other
+	assert.NotEqual(suite.T(), stmp.Canonical("http://example.com/a") == "",
+		stmp.Canonical("http://example.com/b") == "")
+	assert.Contains(suite.T(), suite.export(stmp), "http://example.com/c")
+	assert.Contains(suite.T(), suite.export(stmp), "http://example.com/d")
+	assert.Equal(suite.T(), []CanonicalIssue{
+		{
+			Page:      "http://example.com/a",
+			Canonical: "http://example.com/b",
+			Problem:   LoopingCanonical,
+		},
+		{
+			Page:      "http://example.com/b",
+			Canonical: "http://example.com/a",
+			Problem:   LoopingCanonical,
+		},
+	}, c.Report().CanonicalIssues)
+	assert.Contains(suite.T(), c.Report().String(), "http://example.com/a -> http://example.com/b: loop")
+}
+
+func (suite *CanonicalTestSuite) TestCheckpointKeepsCanonicals() {
+	dir, _ := ioutil.TempDir("", "checkpoint")
+	defer os.RemoveAll(dir)
+
+	newTracker := func() *AsyncHttpTracker {
+		f := NewMockFetcher()
+		t := NewAsyncHttpTracker(f, NewAsyncHTTPParser(suite.seedURL, f, 1))
+		t.SetSitemapper(sitemap.NewGraphSitemap())
+		t.SetCheckpoint(dir, time.Minute)
+		return t
+	}
+	page, _ := url.Parse("http://example.com/sale")
+	canonical, _ := url.Parse("http://example.com/missing")
+	t := newTracker()
+	t.addCanonical(page, canonical)
+	t.statuses[canonical.String()] = 404
+	assert.NoError(suite.T(), t.saveCheckpoint())
+
+	cp, err := loadCheckpoint(dir)
+	assert.NoError(suite.T(), err)
+	restored := newTracker()
+	assert.NoError(suite.T(), restored.restore(cp))
+	assert.Equal(suite.T(), t.checkCanonicals(), restored.checkCanonicals())
+	assert.Len(suite.T(), restored.checkCanonicals(), 1)
+}
+
+func TestCanonicalTestSuite(t *testing.T) {
+	suite.Run(t, new(CanonicalTestSuite))
+}
@@ -0,0 +1,25 @@
+package crawl
+
+import (
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// decodeBody returns body, an HTML page, transcoded to UTF-8,
+// along with the name of the charset it was in. The charset is
+// told by a byte order mark, the charset of contentType, or a
+// <meta charset> or http-equiv Content-Type in the first 1024
+// bytes of body, in that order. Without any, body is assumed to
+// be in UTF-8 if it is valid UTF-8, and in windows-1252 if not.
+// A body that cannot be transcoded is returned as it is
+func decodeBody(body []byte, contentType string) ([]byte, string) {
+	e, name, _ := charset.DetermineEncoding(body, contentType)
+	if e == encoding.Nop || name == "utf-8" {
+		return body, name
+	}
+	decoded, err := e.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, name
+	}
+	return decoded, name
+}
@@ -0,0 +1,79 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+type CharsetTestSuite struct {
+	suite.Suite
+}
+
+// encode returns s encoded with e
+func (suite *CharsetTestSuite) encode(e encoding.Encoding, s string) []byte {
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	assert.NoError(suite.T(), err)
+	return b
+}
+
+func (suite *CharsetTestSuite) TestDecodeBody() {
+	for _, test := range []struct {
+		body        []byte
+		contentType string
+		charset     string
+	}{
+		// The charset of the Content-Type comes before the page's
+		{suite.encode(charmap.ISO8859_1, `<meta charset="utf-8"><p>Café</p>`),
+			"text/html; charset=ISO-8859-1", "windows-1252"},
+		{suite.encode(japanese.ShiftJIS, `<meta charset="Shift_JIS"><p>日本語</p>`),
+			"text/html", "shift_jis"},
+		{suite.encode(charmap.Windows1251,
+			`<meta http-equiv="Content-Type" content="text/html; charset=windows-1251"><p>Привет</p>`),
+			"", "windows-1251"},
+		// A byte order mark comes first
+		{suite.encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), `<p>Привет</p>`),
+			"text/html; charset=windows-1251", "utf-16le"},
+	} {
+		body, charset := decodeBody(test.body, test.contentType)
+		assert.Equal(suite.T(), test.charset, charset)
+		assert.Regexp(suite.T(), `<p>(Café|日本語|Привет)</p>$`, string(body))
+	}
+
+	// Pages in UTF-8 are left as they are
+	body, charset := decodeBody([]byte("<p>日本語</p>"), "")
+	assert.Equal(suite.T(), "utf-8", charset)
+	assert.Equal(suite.T(), "<p>日本語</p>", string(body))
+}
+
+func (suite *CharsetTestSuite) TestExtractLinksDecodesPage() {
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	p := NewAsyncHTTPParser(seedURL, NewMockFetcher(), 1)
+	body := suite.encode(japanese.ShiftJIS, `<html><head>
+		<meta http-equiv="content-type" content="text/html; charset=shift_jis">
+		<title>日本語のページ</title></head>
+		<body><a href="/日本">日本</a></body></html>`)
+
+	links, info := p.extractLinks(&FetchMessage{
+		Request: NewRequest(seedURL),
+		Response: &Response{
+			Header: http.Header{"Content-Type": {"text/html"}},
+			Body:   body,
+		},
+	})
+	assert.Equal(suite.T(), "shift_jis", info.charset)
+	assert.Equal(suite.T(), "日本語のページ", info.title)
+	assert.Len(suite.T(), links, 1)
+	assert.Equal(suite.T(), "/日本", links[0].URL.Path)
+}
+
+func TestCharsetTestSuite(t *testing.T) {
+	suite.Run(t, new(CharsetTestSuite))
+}
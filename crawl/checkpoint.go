@@ -0,0 +1,299 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/antoniou/go-crawler/util"
+)
+
+// checkpointFile is the file of the state
+// directory a crawl is checkpointed to
+const checkpointFile = "checkpoint.json"
+
+// checkpoint is the state of a crawl, saved by the Tracker
+// from time to time, from which the crawl can be resumed.
+// Pending holds the URLs not crawled yet, starting with
+// those the Fetcher and Parser were working on
+type checkpoint struct {
+	Seed    string
+	Elapsed time.Duration
+	Pending []pendingRequest
+	Limited bool
+
+	// HeldSeeds are the seed URLs waiting for the robots.txt
+	// file of their host. The links held along with them are
+	// those of pages still pending, which are crawled again
+	HeldSeeds []string `json:",omitempty"`
+
+	// Redirected maps the pending URLs that were redirected
+	// to the page the redirects led to, or to "" when that
+	// page is not to be tracked
+	Redirected map[string]string
+
+	Pages          int
+	Links          int
+	Bytes          int64
+	Limit          string
+	BeyondDepth    int `json:",omitempty"`
+	Failures       map[string]string
+	Redirects      int
+	RedirectChains [][]Redirect
+	RedirectLoops  [][]Redirect
+
+	// Canonicals and Statuses are the canonical URLs declared
+	// and the statuses other than 200, by URL
+	Canonicals map[string]string `json:",omitempty"`
+	Statuses   map[string]int    `json:",omitempty"`
+
+	Seen    []byte
+	Sitemap json.RawMessage
+}
+
+// pendingRequest is a Request saved with a checkpoint
+type pendingRequest struct {
+	URL      string
+	Depth    int
+	Referrer string `json:",omitempty"`
+	Priority int    `json:",omitempty"`
+}
+
+// newPendingRequest returns req as saved with a checkpoint
+func newPendingRequest(req *Request) pendingRequest {
+	p := pendingRequest{
+		URL:      req.URL.String(),
+		Depth:    req.Depth,
+		Priority: req.Priority,
+	}
+	if req.Referrer != nil {
+		p.Referrer = req.Referrer.String()
+	}
+	return p
+}
+
+// request returns the Request p was saved from
+func (p pendingRequest) request() (*Request, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	req := NewRequest(u)
+	req.Depth = p.Depth
+	req.Priority = p.Priority
+	if p.Referrer != "" {
+		if req.Referrer, err = url.Parse(p.Referrer); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// save writes the checkpoint to dir. The previous checkpoint
+// is only replaced once the new one is completely written
+func (cp *checkpoint) save(dir string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, checkpointFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, checkpointFile))
+}
+
+// loadCheckpoint reads the last checkpoint saved to dir
+func loadCheckpoint(dir string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("Invalid checkpoint in %s: %v", dir, err)
+	}
+	return cp, nil
+}
+
+// checkpoint saves the state of the crawl to the Tracker's
+// state directory. A failure to do so is logged, and the
+// crawl carries on regardless
+func (t *AsyncHttpTracker) checkpoint() {
+	if err := t.saveCheckpoint(); err != nil {
+		t.logger.Printf("Could not checkpoint the crawl to %s: %v", t.stateDir, err)
+		return
+	}
+	util.Printf("Tracker: Checkpointed %d pages crawled, %d pending\n",
+		t.completed, t.enqueued-t.completed)
+}
+
+func (t *AsyncHttpTracker) saveCheckpoint() error {
+	seen, ok := t.seen.(PersistentSeenSet)
+	if !ok {
+		return fmt.Errorf("%T cannot be saved", t.seen)
+	}
+	stmp, ok := t.sitemapper.(json.Marshaler)
+	if !ok {
+		return fmt.Errorf("%T cannot be saved", t.sitemapper)
+	}
+
+	cp := &checkpoint{
+		Elapsed:        time.Since(t.started),
+		Pending:        make([]pendingRequest, 0, t.enqueued-t.completed),
+		Limited:        t.limited,
+		Redirected:     make(map[string]string),
+		Pages:          t.completed,
+		Links:          t.tracked,
+		Bytes:          t.bytes,
+		Limit:          t.report.Limit,
+		BeyondDepth:    t.report.BeyondDepth,
+		Failures:       make(map[string]string),
+		Redirects:      t.report.Redirects,
+		RedirectChains: t.report.RedirectChains,
+		RedirectLoops:  t.report.RedirectLoops,
+		Canonicals:     make(map[string]string),
+		Statuses:       t.statuses,
+	}
+	if t.seedURL != nil {
+		cp.Seed = t.seedURL.String()
+	}
+
+	// The order of the URLs the Fetcher and Parser were
+	// working on is lost, sort them to be consistent
+	inflight := make([]pendingRequest, 0, len(t.inflight))
+	for _, req := range t.inflight {
+		inflight = append(inflight, newPendingRequest(req))
+	}
+	sort.Slice(inflight, func(i, j int) bool { return inflight[i].URL < inflight[j].URL })
+	cp.Pending = append(cp.Pending, inflight...)
+	for _, req := range t.frontier {
+		cp.Pending = append(cp.Pending, newPendingRequest(req))
+	}
+	for _, seed := range t.heldSeeds {
+		cp.HeldSeeds = append(cp.HeldSeeds, seed.String())
+	}
+
+	for req, page := range t.redirected {
+		cp.Redirected[req] = ""
+		if page != nil {
+			cp.Redirected[req] = page.String()
+		}
+	}
+	for u, err := range t.report.Failures {
+		cp.Failures[u] = err.Error()
+	}
+	for page, canonical := range t.canonicals {
+		cp.Canonicals[page] = canonical.String()
+	}
+
+	var buf bytes.Buffer
+	if err := seen.Save(&buf); err != nil {
+		return err
+	}
+	cp.Seen = buf.Bytes()
+
+	var err error
+	if cp.Sitemap, err = stmp.MarshalJSON(); err != nil {
+		return err
+	}
+	return cp.save(t.stateDir)
+}
+
+// restore brings the Tracker back to the state saved
+// in cp, before it is run. The URLs pending are passed
+// on to the Fetcher first, and the seeds held are
+// seeded again once it runs
+func (t *AsyncHttpTracker) restore(cp *checkpoint) error {
+	seen, ok := t.seen.(PersistentSeenSet)
+	if !ok {
+		return fmt.Errorf("%T cannot be restored", t.seen)
+	}
+	if err := seen.Load(bytes.NewReader(cp.Seen)); err != nil {
+		return err
+	}
+	if cp.Seed != "" {
+		seed, err := url.Parse(cp.Seed)
+		if err != nil {
+			return err
+		}
+		t.seedURL = seed
+		t.sitemapper.SetSeedURL(cp.Seed)
+	}
+
+	t.frontier = make([]*Request, 0, len(cp.Pending))
+	for _, p := range cp.Pending {
+		req, err := p.request()
+		if err != nil {
+			return err
+		}
+		t.frontier = append(t.frontier, req)
+	}
+	for _, seed := range cp.HeldSeeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return err
+		}
+		t.heldSeeds = append(t.heldSeeds, u)
+	}
+	for req, page := range cp.Redirected {
+		t.redirected[req] = nil
+		if page != "" {
+			u, err := url.Parse(page)
+			if err != nil {
+				return err
+			}
+			t.redirected[req] = u
+		}
+	}
+
+	t.started = time.Now().Add(-cp.Elapsed)
+	t.limited = cp.Limited
+	t.completed = cp.Pages
+	t.enqueued = cp.Pages + len(cp.Pending)
+	t.tracked = cp.Links
+	t.bytes = cp.Bytes
+	t.report.Limit = cp.Limit
+	t.report.BeyondDepth = cp.BeyondDepth
+	t.report.Redirects = cp.Redirects
+	t.report.RedirectChains = cp.RedirectChains
+	t.report.RedirectLoops = cp.RedirectLoops
+	for u, err := range cp.Failures {
+		t.report.Failures[u] = errors.New(err)
+	}
+	for page, canonical := range cp.Canonicals {
+		u, err := url.Parse(canonical)
+		if err != nil {
+			return err
+		}
+		t.canonicals[page] = u
+	}
+	for u, status := range cp.Statuses {
+		t.statuses[u] = status
+	}
+
+	// A crawl checkpointed once over is over already
+	if t.completed == t.enqueued && len(t.heldSeeds) == 0 {
+		t.end()
+	}
+	return nil
+}
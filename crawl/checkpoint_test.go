@@ -0,0 +1,182 @@
+package crawl
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckpointTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+	dir     string
+}
+
+func (suite *CheckpointTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+	suite.dir, _ = ioutil.TempDir("", "checkpoint")
+}
+
+func (suite *CheckpointTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+// interruptedCrawl returns the state directory of a crawl of
+// checkpointSite checkpointed while /b was still being fetched
+func (suite *CheckpointTestSuite) interruptedCrawl() string {
+	client := newHeldHTTPClient(checkpointSite, "http://example.com/b")
+	running := filepath.Join(suite.dir, "running")
+	c := NewTestCrawler(client, 2, 2)
+	c.SetCheckpoint(running, 5*time.Millisecond)
+	crawled := make(chan struct{})
+	go func() {
+		c.Crawl(context.Background(), suite.seedURL)
+		close(crawled)
+	}()
+	defer func() {
+		close(client.release)
+		<-crawled
+	}()
+
+	// Copy the first checkpoint taken once every other page is done
+	interrupted := filepath.Join(suite.dir, "interrupted")
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		cp, err := loadCheckpoint(running)
+		if err != nil || cp.Pages < 3 {
+			continue
+		}
+		assert.NoError(suite.T(), cp.save(interrupted))
+		return interrupted
+	}
+	suite.T().Fatal("The crawl was never checkpointed")
+	return ""
+}
+
+func (suite *CheckpointTestSuite) TestCheckpointHoldsPendingPages() {
+	cp, err := loadCheckpoint(suite.interruptedCrawl())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "http://example.com", cp.Seed)
+	assert.Equal(suite.T(), 3, cp.Pages)
+	assert.Equal(suite.T(), []pendingRequest{
+		{URL: "http://example.com/b", Depth: 1, Referrer: "http://example.com"},
+	}, cp.Pending)
+}
+
+func (suite *CheckpointTestSuite) TestResume() {
+	dir := suite.interruptedCrawl()
+
+	client := &countingHTTPClient{siteHTTPClient: siteHTTPClient{pages: checkpointSite}}
+	c := NewTestCrawler(client, 2, 2)
+	c.SetCheckpoint(dir, time.Minute)
+	assert.NoError(suite.T(), c.Resume(dir))
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// Only the pages pending are fetched
+	assert.Equal(suite.T(), 2, client.count())
+	assert.Equal(suite.T(), 5, c.Report().Pages)
+	assert.Equal(suite.T(), 5, c.Report().Seen.URLs)
+	assert.ElementsMatch(suite.T(),
+		[]string{"http://example.com/a", "http://example.com/b"},
+		*stmp.LinksFrom("http://example.com"))
+	assert.Equal(suite.T(), []string{"http://example.com/b/1"}, *stmp.LinksFrom("http://example.com/b"))
+
+	// The crawl is over, resuming it again fetches nothing
+	client = &countingHTTPClient{siteHTTPClient: siteHTTPClient{pages: checkpointSite}}
+	c = NewTestCrawler(client, 2, 2)
+	assert.NoError(suite.T(), c.Resume(dir))
+	_, err = c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, client.count())
+	assert.Equal(suite.T(), 5, c.Report().Pages)
+}
+
+func (suite *CheckpointTestSuite) TestResumeHeldSeeds() {
+	site := map[string]string{"http://example.com/robots.txt": "User-agent: *\nDisallow: /b\n"}
+	for k, v := range checkpointSite {
+		site[k] = v
+	}
+	client := newHeldHTTPClient(site, "http://example.com/robots.txt")
+	c := NewTestCrawler(client, 2, 2)
+	c.RespectRobots(DefaultUserAgent)
+	c.SetCheckpoint(suite.dir, 5*time.Millisecond)
+	crawled := make(chan struct{})
+	go func() {
+		c.Crawl(context.Background(), suite.seedURL)
+		close(crawled)
+	}()
+
+	// The seed waiting for robots.txt is checkpointed
+	<-client.requested
+	var cp *checkpoint
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		if cp, _ = loadCheckpoint(suite.dir); cp != nil {
+			break
+		}
+	}
+	assert.NotNil(suite.T(), cp)
+	assert.Equal(suite.T(), []string{"http://example.com"}, cp.HeldSeeds)
+	held := filepath.Join(suite.dir, "held")
+	assert.NoError(suite.T(), cp.save(held))
+	close(client.release)
+	<-crawled
+
+	c = NewTestCrawler(&siteHTTPClient{pages: site}, 2, 2)
+	c.RespectRobots(DefaultUserAgent)
+	assert.NoError(suite.T(), c.Resume(held))
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, c.Report().Pages)
+	assert.Equal(suite.T(), []string{"http://example.com/a"}, *stmp.LinksFrom("http://example.com"))
+}
+
+func (suite *CheckpointTestSuite) TestResumeChecksSeed() {
+	dir := suite.interruptedCrawl()
+	other, _ := url.ParseRequestURI("http://other.com")
+	c := NewTestCrawler(&siteHTTPClient{pages: checkpointSite}, 1, 1)
+	assert.NoError(suite.T(), c.Resume(dir))
+	_, err := c.Crawl(context.Background(), other)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CheckpointTestSuite) TestInterruptKeepsPendingPages() {
+	site := map[string]string{
+		"http://example.com": `
+			<a href="/a">A</a>
+			<a href="/b">B</a>
+			<a href="/c">C</a>`,
+	}
+	client := newHeldHTTPClient(site, "http://example.com/a")
+	c := NewTestCrawler(client, 1, 1)
+	c.SetCheckpoint(suite.dir, time.Minute)
+	go func() {
+		<-client.requested
+		c.Interrupt()
+		time.Sleep(10 * time.Millisecond)
+		close(client.release)
+	}()
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "interrupted", stmp.Incomplete())
+
+	// The pages dropped are left for the crawl to be resumed
+	cp, err := loadCheckpoint(suite.dir)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, cp.Pages)
+	assert.Equal(suite.T(), []pendingRequest{
+		{URL: "http://example.com/a", Depth: 1, Referrer: "http://example.com"},
+		{URL: "http://example.com/b", Depth: 1, Referrer: "http://example.com"},
+		{URL: "http://example.com/c", Depth: 1, Referrer: "http://example.com"},
+	}, cp.Pending)
+}
+
+func TestCheckpointTestSuite(t *testing.T) {
+	suite.Run(t, new(CheckpointTestSuite))
+}
@@ -0,0 +1,201 @@
+package crawl
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/antoniou/go-crawler/sitemap"
+)
+
+// Logger is what the crawler reports the problems it runs
+// into to, such as pages that could not be fetched. A
+// *log.Logger is a Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdLogger is the Logger writing
+// to the standard logger of package log
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Output(2, fmt.Sprintf(format, v...))
+}
+
+// Config holds what an AsyncHTTPCrawler is built with. Zero
+// values are replaced with the defaults of DefaultConfig
+type Config struct {
+	// Client sends the requests of the crawl
+	Client HTPPClient
+
+	// Sitemapper is the sitemap the crawl is added to. It
+	// needs to be a json.Marshaler to be checkpointed
+	Sitemapper sitemap.Sitemapper
+
+	// Scope holds the URLs crawled, which are those in
+	// the DefaultScope of any seed URL if it is nil
+	Scope Scope
+
+	// SeenSet holds the URLs seen during the crawl
+	SeenSet SeenSet
+
+	// QueueSize is the capacity of the queues
+	// passing work from one worker to the next
+	QueueSize int
+
+	// Fetchers and Parsers are the number of pages
+	// fetched and parsed in parallel respectively
+	Fetchers int
+	Parsers  int
+
+	// LinkPolicy tells which kinds of links are crawled
+	// and which are only recorded in the sitemap
+	LinkPolicy LinkPolicy
+
+	// Directives tells what is done with the robots
+	// directives of pages and links
+	Directives DirectivePolicy
+
+	// Bodies larger than MaxBodySize bytes are cut or
+	// fail their page, as BodyLimit says. 0 is no limit
+	MaxBodySize int64
+	BodyLimit   BodyLimit
+
+	// UserAgent is sent as the User-Agent
+	// header of the requests, if not empty
+	UserAgent string
+
+	// Priority, if not nil, sets the Priority of
+	// the Requests, higher ones being crawled first
+	Priority PriorityFunc
+
+	// Logger is what problems are reported to
+	Logger Logger
+
+	// Middleware are hooked into the
+	// crawl, in the order they are given
+	Middleware []Middleware
+}
+
+// DefaultConfig returns the Config of a crawler fetching pages
+// with a NetHTTPClient and building a GraphSitemap, the URLs
+// seen being held in a HashSeenSet
+func DefaultConfig() Config {
+	return Config{
+		Client:      NewNetHTTPClient(NewHTTPClient()),
+		QueueSize:   defaultChannelSize,
+		Fetchers:    DefaultConcurrency,
+		Parsers:     DefaultConcurrency,
+		LinkPolicy:  DefaultLinkPolicy(),
+		MaxBodySize: DefaultMaxBodySize,
+		Logger:      stdLogger{},
+	}
+}
+
+// An Option changes the Config of a crawler
+type Option func(*Config)
+
+// WithClient makes the crawler send its requests with client
+func WithClient(client HTPPClient) Option {
+	return func(c *Config) { c.Client = client }
+}
+
+// WithSitemapper makes the crawler add the crawl to s
+func WithSitemapper(s sitemap.Sitemapper) Option {
+	return func(c *Config) { c.Sitemapper = s }
+}
+
+// WithScope sets the Scope of the crawl
+func WithScope(s Scope) Option {
+	return func(c *Config) { c.Scope = s }
+}
+
+// WithSeenSet sets the SeenSet holding the URLs seen
+func WithSeenSet(s SeenSet) Option {
+	return func(c *Config) { c.SeenSet = s }
+}
+
+// WithQueueSize sets the capacity of the queues
+// passing work from one worker to the next
+func WithQueueSize(size int) Option {
+	return func(c *Config) { c.QueueSize = size }
+}
+
+// WithFetchers sets the number of pages fetched in parallel
+func WithFetchers(n int) Option {
+	return func(c *Config) { c.Fetchers = n }
+}
+
+// WithParsers sets the number of pages parsed in parallel
+func WithParsers(n int) Option {
+	return func(c *Config) { c.Parsers = n }
+}
+
+// WithLinkPolicy sets which kinds of links are crawled
+// and which are only recorded in the sitemap
+func WithLinkPolicy(l LinkPolicy) Option {
+	return func(c *Config) { c.LinkPolicy = l }
+}
+
+// WithDirectivePolicy sets what is done with the
+// robots directives of pages and links
+func WithDirectivePolicy(d DirectivePolicy) Option {
+	return func(c *Config) { c.Directives = d }
+}
+
+// WithMaxBodySize makes the crawler cut the bodies larger
+// than size bytes, or fail their page, as limit says. A
+// size of 0 lifts the limit
+func WithMaxBodySize(size int64, limit BodyLimit) Option {
+	return func(c *Config) {
+		c.MaxBodySize = size
+		c.BodyLimit = limit
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Config) { c.UserAgent = userAgent }
+}
+
+// WithPriority makes the crawler crawl the Requests
+// f gives a higher Priority first
+func WithPriority(f PriorityFunc) Option {
+	return func(c *Config) { c.Priority = f }
+}
+
+// WithLogger makes the crawler report problems to l
+func WithLogger(l Logger) Option {
+	return func(c *Config) { c.Logger = l }
+}
+
+// WithMiddleware adds m to the Middleware hooked into the crawl
+func WithMiddleware(m ...Middleware) Option {
+	return func(c *Config) { c.Middleware = append(c.Middleware, m...) }
+}
+
+// withDefaults returns c, its zero values replaced with
+// those of DefaultConfig, but for MaxBodySize, whose
+// zero value is no limit
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Client == nil {
+		c.Client = d.Client
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = d.QueueSize
+	}
+	if c.Fetchers <= 0 {
+		c.Fetchers = d.Fetchers
+	}
+	if c.Parsers <= 0 {
+		c.Parsers = d.Parsers
+	}
+	if c.LinkPolicy == nil {
+		c.LinkPolicy = d.LinkPolicy
+	}
+	if c.Logger == nil {
+		c.Logger = d.Logger
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingLogger is a Logger keeping what it is given
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+type ConfigTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *ConfigTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+func (suite *ConfigTestSuite) TestDefaults() {
+	c := NewCrawlerFromConfig(Config{})
+	assert.IsType(suite.T(), &NetHTTPClient{}, c.fetcher.client)
+	assert.Equal(suite.T(), DefaultConcurrency, c.fetcher.concurrency)
+	assert.Equal(suite.T(), DefaultConcurrency, c.parser.concurrency)
+	assert.Equal(suite.T(), defaultChannelSize, cap(*c.fetcher.RequestChannel()))
+	assert.Equal(suite.T(), defaultChannelSize, cap(*c.parser.ResponseChannel()))
+	assert.IsType(suite.T(), &HashSeenSet{}, c.tracker.seen)
+	assert.Equal(suite.T(), stdLogger{}, c.tracker.logger)
+}
+
+func (suite *ConfigTestSuite) TestOptions() {
+	stmp := sitemap.NewGraphSitemap()
+	seen := NewBloomSeenSet(100, 0.01)
+	c := NewCrawler(
+		WithClient(&siteHTTPClient{pages: testSite}),
+		WithSitemapper(stmp),
+		WithScope(SameHost(suite.seedURL)),
+		WithSeenSet(seen),
+		WithQueueSize(5),
+		WithFetchers(3),
+		WithParsers(2),
+	)
+	assert.Equal(suite.T(), 5, cap(*c.fetcher.ResponseChannel()))
+	assert.Equal(suite.T(), 3, c.fetcher.concurrency)
+	assert.Equal(suite.T(), 2, c.parser.concurrency)
+
+	crawled, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), crawled == stmp)
+	assert.Equal(suite.T(), 6, seen.Stats().URLs)
+	assert.Len(suite.T(), *stmp.LinksFrom("http://example.com/news"), 2)
+}
+
+func (suite *ConfigTestSuite) TestLogger() {
+	logger := &recordingLogger{}
+	c := NewCrawler(WithClient(&mockHTTPClient{}), WithLogger(logger))
+	seedURL, _ := url.ParseRequestURI("http://nonexistingwebsite.com")
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"Could not get http://nonexistingwebsite.com: no such host"}, logger.lines)
+}
+
+func (suite *ConfigTestSuite) TestUserAgent() {
+	agents := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.URL.Path + " " + r.UserAgent()
+	}))
+	defer server.Close()
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewCrawler(WithUserAgent("go-crawler/1.0"))
+	c.RespectRobots(DefaultUserAgent)
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "/robots.txt go-crawler/1.0", <-agents)
+	assert.Equal(suite.T(), "/ go-crawler/1.0", <-agents)
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
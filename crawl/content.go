@@ -0,0 +1,78 @@
+package crawl
+
+import (
+	"mime"
+	"net/http"
+)
+
+// DefaultMaxBodySize is the size bodies are
+// cut at, unless configured otherwise
+const DefaultMaxBodySize = 10 << 20
+
+// BodyLimit tells what happens to the bodies
+// larger than the maximum body size
+type BodyLimit int
+
+// Possible BodyLimits
+const (
+	// TruncateBody cuts them at the maximum
+	// size, the page being parsed all the same
+	TruncateBody BodyLimit = iota
+	// AbortBody fails the page
+	AbortBody
+)
+
+// sniffLen is the number of bytes of a
+// body its media type is sniffed from
+const sniffLen = 512
+
+// htmlMediaTypes are the media types
+// of the pages parsed for links
+var htmlMediaTypes = []string{"text/html", "application/xhtml+xml"}
+
+// IsHTML returns whether mediaType is of the
+// HTML family, the only one parsed for links
+func IsHTML(mediaType string) bool {
+	return hasMediaType(htmlMediaTypes, mediaType)
+}
+
+// MediaType returns the media type of a response, as declared
+// by its Content-Type header or, when the header is missing or
+// only says application/octet-stream, as sniffed from its body
+func MediaType(header http.Header, body []byte) string {
+	declared := declaredMediaType(header)
+	if (declared == "" || declared == "application/octet-stream") && len(body) > 0 {
+		sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+		return sniffed
+	}
+	return declared
+}
+
+// responseMediaType returns the media type of res, sniffed
+// from its body if the HTPPClient did not tell it
+func responseMediaType(res *Response) string {
+	if res.MediaType != "" {
+		return res.MediaType
+	}
+	return MediaType(res.Header, res.Body)
+}
+
+// declaredMediaType returns the media type of the
+// Content-Type header, or "" if there is none
+func declaredMediaType(header http.Header) string {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// hasMediaType returns whether mediaType is one of mediaTypes
+func hasMediaType(mediaTypes []string, mediaType string) bool {
+	for _, t := range mediaTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+
+	// pdfBytes counts the bytes of the PDF sent
+	pdfBytes int64
+}
+
+func (suite *ContentTestSuite) SetupTest() {
+	atomic.StoreInt64(&suite.pdfBytes, 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/report.pdf">Report</a><a href="/logo">Logo</a><a href="/long">Long</a>`)
+	})
+	mux.HandleFunc("/octets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, `<html><body><a href="/blob">Blob</a></body></html>`)
+	})
+	mux.HandleFunc("/blob", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, strings.Repeat("\x00", 1<<16))
+	})
+	mux.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		n, _ := fmt.Fprint(w, strings.Repeat("%PDF", 1<<16))
+		atomic.AddInt64(&suite.pdfBytes, int64(n))
+	})
+	mux.HandleFunc("/logo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "\x89PNG\x0D\x0A\x1A\x0A<a href=\"/hidden\">Hidden</a>")
+	})
+	mux.HandleFunc("/long", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/kept">Kept</a>`+strings.Repeat(" ", 100)+`<a href="/cut">Cut</a>`+
+			`<a href="/octets">Octets</a>`)
+	})
+	mux.HandleFunc("/kept", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/cut", func(w http.ResponseWriter, r *http.Request) {})
+	suite.server = httptest.NewServer(mux)
+}
+
+func (suite *ContentTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *ContentTestSuite) TestMediaType() {
+	html := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	assert.Equal(suite.T(), "text/html", MediaType(html, nil))
+	pdf := http.Header{"Content-Type": {"Application/PDF"}}
+	assert.Equal(suite.T(), "application/pdf", MediaType(pdf, []byte("<html>")))
+
+	// Missing or vague headers are sniffed
+	assert.Equal(suite.T(), "text/html", MediaType(http.Header{}, []byte("\n  <a href=\"/\">Home</a>")))
+	octets := http.Header{"Content-Type": {"application/octet-stream"}}
+	assert.Equal(suite.T(), "image/png", MediaType(octets, []byte("\x89PNG\x0D\x0A\x1A\x0A")))
+	assert.Equal(suite.T(), "application/octet-stream", MediaType(octets, nil))
+	assert.Equal(suite.T(), "", MediaType(http.Header{}, nil))
+
+	assert.True(suite.T(), IsHTML("text/html"))
+	assert.True(suite.T(), IsHTML("application/xhtml+xml"))
+	assert.False(suite.T(), IsHTML("text/plain"))
+}
+
+func (suite *ContentTestSuite) TestOnlyHTMLIsParsed() {
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	c := NewAsyncHTTPCrawler(2, 2)
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	// Resources that are not HTML are leaves, with their media type
+	pages := <-collected
+	assert.Equal(suite.T(), "application/pdf", pages[suite.server.URL+"/report.pdf"].MediaType)
+	assert.Equal(suite.T(), "application/pdf", stmp.MediaType(suite.server.URL+"/report.pdf"))
+	assert.Equal(suite.T(), "image/png", stmp.MediaType(suite.server.URL+"/logo"))
+	assert.Empty(suite.T(), *stmp.LinksFrom(suite.server.URL + "/logo"))
+	assert.Equal(suite.T(), "", stmp.MediaType(suite.server.URL))
+	assert.Equal(suite.T(), "text/html", pages[suite.server.URL].MediaType)
+
+	// Pages served as application/octet-stream are sniffed and parsed
+	assert.Equal(suite.T(), "text/html", pages[suite.server.URL+"/octets"].MediaType)
+	assert.Equal(suite.T(), []string{suite.server.URL + "/blob"}, *stmp.LinksFrom(suite.server.URL + "/octets"))
+	assert.Equal(suite.T(), "application/octet-stream", pages[suite.server.URL+"/blob"].MediaType)
+	assert.Equal(suite.T(), int64(0), pages[suite.server.URL+"/blob"].Bytes)
+
+	// The body of a resource declared not to be HTML is not read
+	assert.Equal(suite.T(), int64(0), pages[suite.server.URL+"/report.pdf"].Bytes)
+	assert.True(suite.T(), atomic.LoadInt64(&suite.pdfBytes) > 0)
+}
+
+func (suite *ContentTestSuite) TestTruncateBody() {
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	c := NewCrawler(WithMaxBodySize(100, TruncateBody))
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The links past the cut are not found
+	long := (<-collected)[suite.server.URL+"/long"]
+	assert.True(suite.T(), long.Truncated)
+	assert.Equal(suite.T(), int64(100), long.Bytes)
+	assert.Equal(suite.T(), []string{suite.server.URL + "/kept"}, *stmp.LinksFrom(suite.server.URL + "/long"))
+}
+
+func (suite *ContentTestSuite) TestAbortBody() {
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	c := NewCrawler(WithMaxBodySize(100, AbortBody))
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	failures := c.Report().Failures
+	assert.EqualError(suite.T(), failures[suite.server.URL+"/long"], "body larger than 100 bytes")
+	assert.NotContains(suite.T(), failures, suite.server.URL)
+}
+
+func (suite *ContentTestSuite) TestNoBodyLimit() {
+	assert.Equal(suite.T(), int64(DefaultMaxBodySize), NewCrawler().fetcher.maxBodySize)
+
+	// A size of 0 lifts the limit
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	c := NewCrawler(WithMaxBodySize(0, AbortBody))
+	assert.Equal(suite.T(), int64(0), c.fetcher.maxBodySize)
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	long := (<-collected)[suite.server.URL+"/long"]
+	assert.False(suite.T(), long.Truncated)
+	assert.Empty(suite.T(), c.Report().Failures)
+	assert.Contains(suite.T(), *stmp.LinksFrom(suite.server.URL+"/long"), suite.server.URL+"/cut")
+}
+
+func (suite *ContentTestSuite) TestMaxBodySizeOfAnyClient() {
+	long := strings.Repeat("a", 100)
+	f := NewAsyncHTTPFetcher(1)
+	f.client = &siteHTTPClient{pages: map[string]string{"http://example.com": long}}
+	f.SetMaxBodySize(10, TruncateBody)
+	defer runWorker(f.Worker())()
+
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	f.Fetch(seedURL)
+	m := <-*f.ResponseChannel()
+	assert.NoError(suite.T(), m.Error)
+	assert.Equal(suite.T(), long[:10], string(m.Response.Body))
+	assert.True(suite.T(), m.Response.Truncated)
+}
+
+func TestContentTestSuite(t *testing.T) {
+	suite.Run(t, new(ContentTestSuite))
+}
@@ -1,8 +1,11 @@
 package crawl
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/antoniou/go-crawler/sitemap"
@@ -12,29 +15,73 @@ import (
 // A Crawler crawls a domain and returns
 // a representation of the crawled domain
 type Crawler interface {
-	//Crawl is the main entrypoint to
-	//crawling a domain (url)
-	Crawl(url string) (sitemap.Sitemapper, error)
+	// Crawl is the main entrypoint to crawling a domain,
+	// from one or more seed URLs. The crawl stops
+	// once ctx is done
+	Crawl(ctx context.Context, seeds ...*url.URL) (sitemap.Sitemapper, error)
 }
 
+var _ Crawler = (*AsyncHTTPCrawler)(nil)
+
 // NewAsyncHTTPCrawler is a constructor. It takes in a Fetcher
 // that will start the crawl and zero or more workers that will
-// process the response and create a Sitemap
-func NewAsyncHTTPCrawler(seedURL *url.URL) *AsyncHTTPCrawler {
+// process the response and create a Sitemap.
+// fetchers and parsers are the number of pages
+// fetched and parsed in parallel respectively
+func NewAsyncHTTPCrawler(fetchers, parsers int) *AsyncHTTPCrawler {
+	return NewCrawler(WithFetchers(fetchers), WithParsers(parsers))
+}
+
+// NewCrawler is a constructor building an
+// AsyncHTTPCrawler from DefaultConfig, as changed by opts
+func NewCrawler(opts ...Option) *AsyncHTTPCrawler {
+	cfg := DefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return NewCrawlerFromConfig(cfg)
+}
 
-	fetcher := NewAsyncHTTPFetcher()
-	parser := NewAsyncHTTPParser(seedURL, fetcher)
+// NewCrawlerFromConfig is a constructor building an
+// AsyncHTTPCrawler from cfg. Its zero values are replaced
+// with those of DefaultConfig, but for MaxBodySize
+func NewCrawlerFromConfig(cfg Config) *AsyncHTTPCrawler {
+	cfg = cfg.withDefaults()
+
+	fetcher := newAsyncHTTPFetcher(cfg.Fetchers, cfg.QueueSize)
+	fetcher.client = cfg.Client
+	fetcher.userAgent = cfg.UserAgent
+	fetcher.SetMaxBodySize(cfg.MaxBodySize, cfg.BodyLimit)
+	fetcher.Use(cfg.Middleware...)
+	parser := newAsyncHTTPParser(nil, fetcher, cfg.Parsers, cfg.QueueSize)
+	parser.SetLogger(cfg.Logger)
+	parser.Use(cfg.Middleware...)
 	tracker := NewAsyncHttpTracker(fetcher, parser)
-	return &AsyncHTTPCrawler{
-		seedURL: seedURL,
-		fetcher: fetcher,
-		tracker: tracker,
+	tracker.SetLogger(cfg.Logger)
+	tracker.SetPriority(cfg.Priority)
+	if cfg.SeenSet != nil {
+		tracker.SetSeenSet(cfg.SeenSet)
+	}
+
+	c := &AsyncHTTPCrawler{
+		fetcher:    fetcher,
+		parser:     parser,
+		tracker:    tracker,
+		sitemapper: cfg.Sitemapper,
+		logger:     cfg.Logger,
+		queueSize:  cfg.QueueSize,
 		workers: []Worker{
 			parser.Worker(),
 			fetcher.Worker(),
 			tracker.Worker(),
 		},
 	}
+	if cfg.Scope != nil {
+		c.SetScope(cfg.Scope)
+	}
+	c.SetLinkPolicy(cfg.LinkPolicy)
+	c.SetDirectivePolicy(cfg.Directives)
+	return c
 }
 
 // AsyncHTTPCrawler is an implementation of the
@@ -42,50 +89,265 @@ func NewAsyncHTTPCrawler(seedURL *url.URL) *AsyncHTTPCrawler {
 // initiates the crawling and zero or more workers
 // that perform the processing
 type AsyncHTTPCrawler struct {
-	fetcher Fetcher
-	tracker Tracker
+	fetcher *AsyncHTTPFetcher
+	parser  *AsyncHTTPParser
+	tracker *AsyncHttpTracker
 	workers []Worker
-	seedURL *url.URL
+	scope   Scope
+	logger  Logger
+
+	// results receives the PageResult of every page
+	// crawled, once Results has been called
+	results   chan PageResult
+	queueSize int
+
+	// sitemapper is the Sitemapper crawls are added
+	// to, or nil for every crawl to get a GraphSitemap
+	sitemapper sitemap.Sitemapper
+
+	// sitemap is the sitemap of a resumed crawl
+	sitemap sitemap.Sitemapper
 }
 
-// Crawl is the main entrypoint to crawling a domain (url).
-// Crawl returns a Sitemapper that can later be used to create a
-// represenation of the crawled site.
-// It returns an error in case the crawl url is invalid
-func (c *AsyncHTTPCrawler) Crawl() (sitemap.Sitemapper, error) {
-	// Create an empty sitemap
-	stmp := sitemap.NewGraphSitemap()
-	// Pass it to the tracker
+// SetScope sets the Scope of the crawl. Unless set otherwise,
+// it holds the URLs in the DefaultScope of any seed URL
+func (c *AsyncHTTPCrawler) SetScope(s Scope) {
+	c.scope = s
+	c.parser.SetScope(s)
+	c.tracker.SetScope(s)
+}
+
+// SetLinkPolicy sets which kinds of links are crawled and
+// which are only recorded in the sitemap
+func (c *AsyncHTTPCrawler) SetLinkPolicy(l LinkPolicy) {
+	c.parser.SetLinkPolicy(l)
+	c.tracker.SetLinkPolicy(l)
+}
+
+// SetDirectivePolicy sets what is done with the
+// robots directives of pages and links
+func (c *AsyncHTTPCrawler) SetDirectivePolicy(d DirectivePolicy) {
+	c.tracker.SetDirectivePolicy(d)
+}
+
+// SetCanonicalizer sets the rules URLs are canonicalized
+// with before telling whether they have been crawled
+func (c *AsyncHTTPCrawler) SetCanonicalizer(canon *util.Canonicalizer) {
+	c.parser.SetCanonicalizer(canon)
+	c.tracker.SetCanonicalizer(canon)
+}
+
+// SetSeenSet sets the SeenSet holding the URLs seen
+// during the crawl, which is a HashSeenSet unless set otherwise
+func (c *AsyncHTTPCrawler) SetSeenSet(s SeenSet) {
+	c.tracker.SetSeenSet(s)
+}
+
+// SetCheckpoint makes the crawler save the state of the crawl
+// to dir every interval, for it to be resumed with Resume
+func (c *AsyncHTTPCrawler) SetCheckpoint(dir string, interval time.Duration) {
+	c.tracker.SetCheckpoint(dir, interval)
+}
+
+// Resume restores the state of the crawl last checkpointed to
+// dir, for Crawl to carry on from where it was rather than
+// start from the seed URLs, which need to be those of the
+// crawl checkpointed. Pages crawled already are not fetched
+// again. Resume needs to be called once the crawler is set up
+// as it was for the crawl checkpointed, SeenSet included
+func (c *AsyncHTTPCrawler) Resume(dir string) error {
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		return err
+	}
+
+	stmp := c.newSitemap()
+	saved, ok := stmp.(json.Unmarshaler)
+	if !ok {
+		return fmt.Errorf("%T cannot be resumed", stmp)
+	}
+	if err := saved.UnmarshalJSON(cp.Sitemap); err != nil {
+		return err
+	}
 	c.tracker.SetSitemapper(stmp)
+	if err := c.tracker.restore(cp); err != nil {
+		return err
+	}
+	c.sitemap = stmp
+	return nil
+}
+
+// newSitemap returns the Sitemapper a crawl is added to
+func (c *AsyncHTTPCrawler) newSitemap() sitemap.Sitemapper {
+	if c.sitemapper != nil {
+		return c.sitemapper
+	}
+	return sitemap.NewGraphSitemap()
+}
+
+// SetGracePeriod sets the time given to the pages being
+// crawled to be done once the crawl is interrupted, which
+// is DefaultGracePeriod unless set otherwise
+func (c *AsyncHTTPCrawler) SetGracePeriod(d time.Duration) {
+	c.tracker.SetGracePeriod(d)
+}
+
+// Interrupt ends the crawl early. No more pages are requested
+// and Crawl returns once the pages being crawled are done, or
+// once the grace period has passed, with the sitemap crawled
+// so far marked as incomplete. A checkpointed crawl is
+// checkpointed once more first, for it to be resumed
+func (c *AsyncHTTPCrawler) Interrupt() {
+	c.tracker.Interrupt()
+}
+
+// SetLimits sets the Limits of the crawl
+func (c *AsyncHTTPCrawler) SetLimits(l Limits) {
+	c.tracker.SetLimits(l)
+}
+
+// SetRateLimiter provides the crawler with a HostLimiter
+// pacing the requests sent to each host
+func (c *AsyncHTTPCrawler) SetRateLimiter(l *HostLimiter) {
+	c.fetcher.SetRateLimiter(l)
+}
 
+// SetRetryPolicy provides the crawler with the
+// policy used to retry failed requests
+func (c *AsyncHTTPCrawler) SetRetryPolicy(p *RetryPolicy) {
+	c.fetcher.SetRetryPolicy(p)
+}
+
+// RespectRobots makes the crawler honour the robots.txt rules
+// for userAgent. robots.txt files are fetched with the Fetcher's
+// client, and their Crawl-delay is passed on to the rate limiter
+// set with SetRateLimiter, if any
+func (c *AsyncHTTPCrawler) RespectRobots(userAgent string) {
+	robots := NewRobots(c.fetcher.client, userAgent, c.fetcher.limiter)
+	robots.header = c.fetcher.userAgent
+	robots.logger = c.logger
+	c.tracker.SetRobots(robots)
+}
+
+// Crawl is the main entrypoint to crawling a domain, from one or
+// more seed URLs. Crawl returns a Sitemapper that can later be
+// used to create a represenation of the crawled site.
+// It returns an error in case a seed URL is invalid. Once ctx is
+// done, the workers are stopped and the requests in progress
+// cancelled. Crawl then returns the sitemap crawled so far,
+// marked as incomplete, along with the error of ctx
+func (c *AsyncHTTPCrawler) Crawl(ctx context.Context, seeds ...*url.URL) (sitemap.Sitemapper, error) {
+	defer c.closeResults()
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("No seed URL to crawl from")
+	}
+	for _, seed := range seeds {
+		if err := validateURL(seed); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.scope == nil {
+		scopes := make([]Scope, 0, len(seeds))
+		for _, seed := range seeds {
+			scopes = append(scopes, DefaultScope(seed))
+		}
+		c.parser.SetScope(AnyScope(scopes...))
+		c.tracker.SetScope(AnyScope(scopes...))
+	}
+
+	// Create an empty sitemap, unless the crawl is resumed
+	stmp := c.sitemap
+	if stmp == nil {
+		stmp = c.newSitemap()
+		// Pass it to the tracker
+		c.tracker.SetSitemapper(stmp)
+	} else if err := c.checkResumed(seeds[0]); err != nil {
+		return nil, err
+	}
+
+	// Cancelling workCtx stops all workers
+	workCtx, stop := context.WithCancel(ctx)
+	var wg sync.WaitGroup
 	for _, worker := range c.workers {
 		util.Printf("Starting worker of type %v\n", worker.Type())
-		go worker.Run()
+		wg.Add(1)
+		go func(w Worker) {
+			defer wg.Done()
+			w.Run(workCtx)
+		}(worker)
+	}
+
+	if c.sitemap != nil {
+		fmt.Printf("Resuming crawling of %v\n", seeds[0])
+	} else {
+		for _, seed := range seeds {
+			fmt.Printf("Starting crawling of %v\n", seed)
+		}
+		c.tracker.Seed(ctx, seeds...)
 	}
 
-	fmt.Printf("Starting crawling of %v\n", c.seedURL)
-	err := c.fetcher.Fetch(c.seedURL)
+	// The Tracker knows when every URL it has
+	// passed on has been fetched and parsed
+	var err error
+	select {
+	case <-c.tracker.Done():
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	util.Printf("Stopping workers\n")
+	stop()
+	wg.Wait()
 	if err != nil {
-		return nil, err
+		c.tracker.cancel()
 	}
 
-	return stmp, c.join()
-}
+	switch report := c.tracker.Report(); {
+	case report.Interrupted:
+		stmp.SetIncomplete("interrupted")
+	case report.Limit != "":
+		stmp.SetIncomplete("reached " + report.Limit)
+	}
 
-// Wait for all workers to be in state WAITING. This
-// will indicate that work is done
-func (c *AsyncHTTPCrawler) join() error {
-	for {
-		time.Sleep(500 * time.Millisecond)
-		state := WAITING
+	return stmp, err
+}
 
-		for _, worker := range c.workers {
-			state += worker.State()
-		}
+// checkResumed returns an error unless seed is
+// the first seed URL of the crawl resumed
+func (c *AsyncHTTPCrawler) checkResumed(seed *url.URL) error {
+	resumed := c.tracker.seedURL
+	if resumed != nil && c.tracker.canonical(seed).String() != resumed.String() {
+		return fmt.Errorf("The crawl resumed is a crawl of %s, not %s", resumed, seed)
+	}
+	return nil
+}
 
-		if state == WAITING {
-			return nil
-		}
+// Results returns a channel receiving the PageResult of every
+// page crawled as soon as it has been parsed, alongside the
+// sitemap being built. It needs to be called before Crawl, and
+// the channel read from for the crawl to carry on. The channel
+// is closed once Crawl returns
+func (c *AsyncHTTPCrawler) Results() <-chan PageResult {
+	if c.results == nil {
+		c.results = make(chan PageResult, c.queueSize)
+		c.parser.SetResults(c.results)
+	}
+	return c.results
+}
 
+// closeResults closes the channel returned by Results, if any
+func (c *AsyncHTTPCrawler) closeResults() {
+	if c.results != nil {
+		close(c.results)
+		c.results = nil
+		c.parser.SetResults(nil)
 	}
 }
+
+// Report returns the Report of the last crawl
+func (c *AsyncHTTPCrawler) Report() *Report {
+	return c.tracker.Report()
+}
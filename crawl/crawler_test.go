@@ -3,6 +3,7 @@
 package crawl
 
 import (
+	"context"
 	"testing"
 
 	"github.com/antoniou/go-crawler/util"
@@ -16,24 +17,24 @@ type CrawlTestSuite struct {
 
 func (suite *CrawlTestSuite) TestInvalidInputCrawler() {
 	seedURL, _ := util.NormalizeStringURL("http://notExistingUrl404.com")
-	crawler := NewAsyncHTTPCrawler(seedURL)
-	sitemap, err := crawler.Crawl()
+	crawler := NewAsyncHTTPCrawler(DefaultConcurrency, DefaultConcurrency)
+	sitemap, err := crawler.Crawl(context.Background(), seedURL)
 
 	assert.NoError(suite.T(), err)
 	_, err = sitemap.SeedURL()
 	assert.Error(suite.T(), err)
 
 	seedURL, _ = util.NormalizeStringURL("ftp://invalidscheme.com")
-	crawler = NewAsyncHTTPCrawler(seedURL)
-	sitemap, err = crawler.Crawl()
+	crawler = NewAsyncHTTPCrawler(DefaultConcurrency, DefaultConcurrency)
+	sitemap, err = crawler.Crawl(context.Background(), seedURL)
 	assert.Error(suite.T(), err)
 	assert.Nil(suite.T(), sitemap)
 }
 
 func (suite *CrawlTestSuite) TestValidInputCrawler() {
 	seedURL, _ := util.NormalizeStringURL("http://tomblomfield.com/about")
-	crawler := NewAsyncHTTPCrawler(seedURL)
-	sitemap, err := crawler.Crawl()
+	crawler := NewAsyncHTTPCrawler(DefaultConcurrency, DefaultConcurrency)
+	sitemap, err := crawler.Crawl(context.Background(), seedURL)
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), sitemap)
 	seed, _ := sitemap.SeedURL()
@@ -0,0 +1,97 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/antoniou/go-crawler/sitemap"
+)
+
+// DirectivePolicy tells what the crawler does with the robots
+// directives of pages and links, as given by rel="nofollow",
+// <meta name="robots"> and the X-Robots-Tag header
+type DirectivePolicy int
+
+// Possible DirectivePolicies
+const (
+	// RespectDirectives records the directives in the sitemap
+	// and does not follow nofollow links, which are recorded
+	// without being crawled
+	RespectDirectives DirectivePolicy = iota
+	// RecordDirectives records the directives in
+	// the sitemap, but crawls as if there were none
+	RecordDirectives
+	// IgnoreDirectives crawls as if there were no directives
+	IgnoreDirectives
+)
+
+// ParseDirectivePolicy returns the DirectivePolicy
+// named s: respect, record or ignore
+func ParseDirectivePolicy(s string) (DirectivePolicy, error) {
+	switch s {
+	case "respect":
+		return RespectDirectives, nil
+	case "record":
+		return RecordDirectives, nil
+	case "ignore":
+		return IgnoreDirectives, nil
+	}
+	return 0, fmt.Errorf("Unknown directive policy %q, expects respect, record or ignore", s)
+}
+
+// parseDirectives returns the noindex and nofollow directives
+// of the content of a robots meta tag or X-Robots-Tag header
+func parseDirectives(content string) []sitemap.Directive {
+	var directives []sitemap.Directive
+	for _, d := range strings.Split(strings.ToLower(content), ",") {
+		switch strings.TrimSpace(d) {
+		case "noindex":
+			directives = addDirectives(directives, sitemap.Noindex)
+		case "nofollow":
+			directives = addDirectives(directives, sitemap.Nofollow)
+		case "none":
+			directives = addDirectives(directives, sitemap.Noindex, sitemap.Nofollow)
+		}
+	}
+	return directives
+}
+
+// headerDirectives returns the directives of the X-Robots-Tag
+// headers in h. Those addressed to a specific crawler, such as
+// "googlebot: noindex", are left out
+func headerDirectives(h http.Header) []sitemap.Directive {
+	var directives []sitemap.Directive
+	for _, v := range h[http.CanonicalHeaderKey("X-Robots-Tag")] {
+		if i := strings.Index(v, ":"); i >= 0 && !strings.ContainsAny(v[:i], ", ") {
+			if name := strings.ToLower(v[:i]); !strings.HasPrefix(name, "unavailable_after") &&
+				!strings.HasPrefix(name, "max-") {
+				continue
+			}
+		}
+		directives = addDirectives(directives, parseDirectives(v)...)
+	}
+	return directives
+}
+
+// addDirectives adds the directives to
+// those in directives that are missing
+func addDirectives(directives []sitemap.Directive, more ...sitemap.Directive) []sitemap.Directive {
+	for _, d := range more {
+		if !sitemap.HasDirective(directives, d) {
+			directives = append(directives, d)
+		}
+	}
+	return directives
+}
+
+// hasToken returns whether the space-separated
+// list s holds token, ignoring case
+func hasToken(s, token string) bool {
+	for _, t := range strings.Fields(s) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var directivesSite = map[string]string{
+	"http://example.com": `
+		<html><body>
+		<a href="/about">About</a>
+		<a href="/ads" rel="sponsored NoFollow">Ads</a>
+		<a href="/private">Private</a>
+		<a href="/hidden">Hidden</a>
+		</body></html>`,
+	"http://example.com/about": `
+		<html><head><meta name="robots" content="noindex"></head>
+		<body><a href="/team">Team</a></body></html>`,
+	"http://example.com/private": `
+		<html><head><meta name="ROBOTS" content="nofollow"></head>
+		<body><a href="/secret">Secret</a></body></html>`,
+	"http://example.com/hidden": `<html><body><a href="/deep">Deep</a></body></html>`,
+	"http://example.com/ads":    `<html></html>`,
+	"http://example.com/team":   `<html></html>`,
+	"http://example.com/secret": `<html></html>`,
+	"http://example.com/deep":   `<html></html>`,
+}
+
+type DirectivesTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *DirectivesTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+// crawl crawls the directivesSite with policy, returning
+// the sitemap and the pages crawled
+func (suite *DirectivesTestSuite) crawl(policy DirectivePolicy) (sitemap.Sitemapper, map[string]PageResult) {
+	client := &siteHTTPClient{
+		pages: directivesSite,
+		headers: map[string]http.Header{
+			"http://example.com/hidden": {"X-Robots-Tag": {"none"}},
+		},
+	}
+	c := NewCrawler(WithClient(client), WithFetchers(2), WithParsers(2),
+		WithDirectivePolicy(policy))
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	return stmp, <-collected
+}
+
+func (suite *DirectivesTestSuite) TestParseDirectives() {
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex},
+		parseDirectives("NoIndex, follow"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow, sitemap.Noindex},
+		parseDirectives("nofollow,noindex,nofollow"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex, sitemap.Nofollow},
+		parseDirectives(" none "))
+	assert.Empty(suite.T(), parseDirectives("all, noarchive"))
+}
+
+func (suite *DirectivesTestSuite) TestHeaderDirectives() {
+	h := http.Header{}
+	h.Add("X-Robots-Tag", "noarchive, nofollow")
+	h.Add("X-Robots-Tag", "otherbot: noindex")
+	h.Add("X-Robots-Tag", "unavailable_after: 25 Jun 2030 15:00:00 PST")
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow}, headerDirectives(h))
+
+	h.Add("X-Robots-Tag", "noindex, unavailable_after: 25 Jun 2030 15:00:00 PST")
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow, sitemap.Noindex}, headerDirectives(h))
+	assert.Empty(suite.T(), headerDirectives(nil))
+}
+
+func (suite *DirectivesTestSuite) TestParseDirectivePolicy() {
+	d, err := ParseDirectivePolicy("record")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), RecordDirectives, d)
+	_, err = ParseDirectivePolicy("obey")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *DirectivesTestSuite) TestRespectDirectives() {
+	stmp, pages := suite.crawl(RespectDirectives)
+
+	// nofollow links, and the links of nofollow
+	// pages, are recorded without being crawled
+	for _, u := range []string{"http://example.com/about", "http://example.com/private",
+		"http://example.com/hidden", "http://example.com/team"} {
+		assert.Contains(suite.T(), pages, u)
+	}
+	for _, edge := range [][2]string{
+		{"http://example.com", "http://example.com/ads"},
+		{"http://example.com/private", "http://example.com/secret"},
+		{"http://example.com/hidden", "http://example.com/deep"},
+	} {
+		assert.NotContains(suite.T(), pages, edge[1])
+		assert.Equal(suite.T(), sitemap.LinkEdge, stmp.EdgeKind(edge[0], edge[1]))
+		assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow},
+			stmp.EdgeDirectives(edge[0], edge[1]))
+	}
+	assert.Empty(suite.T(), stmp.EdgeDirectives("http://example.com", "http://example.com/about"))
+
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex},
+		stmp.Directives("http://example.com/about"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow},
+		stmp.Directives("http://example.com/private"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex, sitemap.Nofollow},
+		stmp.Directives("http://example.com/hidden"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex, sitemap.Nofollow},
+		pages["http://example.com/hidden"].Directives)
+	assert.True(suite.T(), pages["http://example.com/hidden"].Links[0].Nofollow)
+}
+
+func (suite *DirectivesTestSuite) TestRecordDirectives() {
+	stmp, pages := suite.crawl(RecordDirectives)
+
+	// Every page is crawled, the directives being recorded all the same
+	assert.Len(suite.T(), pages, len(directivesSite))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow},
+		stmp.EdgeDirectives("http://example.com", "http://example.com/ads"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Nofollow},
+		stmp.EdgeDirectives("http://example.com/hidden", "http://example.com/deep"))
+	assert.Equal(suite.T(), []sitemap.Directive{sitemap.Noindex},
+		stmp.Directives("http://example.com/about"))
+}
+
+func (suite *DirectivesTestSuite) TestIgnoreDirectives() {
+	stmp, pages := suite.crawl(IgnoreDirectives)
+
+	assert.Len(suite.T(), pages, len(directivesSite))
+	assert.Empty(suite.T(), stmp.EdgeDirectives("http://example.com", "http://example.com/ads"))
+	assert.Empty(suite.T(), stmp.Directives("http://example.com/about"))
+	assert.Empty(suite.T(), stmp.Directives("http://example.com/hidden"))
+}
+
+func TestDirectivesTestSuite(t *testing.T) {
+	suite.Run(t, new(DirectivesTestSuite))
+}
@@ -1,14 +1,22 @@
 package crawl
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/antoniou/go-crawler/util"
 )
 
-const defaultChannelSize = 100
+const (
+	defaultChannelSize = 100
+
+	// DefaultConcurrency is the number of goroutines
+	// a worker pool runs when none is configured
+	DefaultConcurrency = 1
+)
 
 // Fetcher is an Asynchronous Worker interface
 // that is responsible for Fetching URLs and
@@ -19,6 +27,11 @@ type Fetcher interface {
 	// form of a URL to process
 	Fetch(url *url.URL) error
 
+	// RequestChannel is a Getter returning
+	// the Fetcher's Channel that producers
+	// can place requests on
+	RequestChannel() (requestQueue *RequestQueue)
+
 	// ResponseChannel is a Getter returning
 	// the Fetcher's Channel  that consumers
 	// should be receiving results from
@@ -31,17 +44,36 @@ type Fetcher interface {
 // FetchMessage is a struct used to pass results of a Fetch
 // request back to the requester. It includes
 // Request: The original request (for tracking)
-// Response
+// Response, if any, even when the request failed
 // Error in case request could not finish successfully
 type FetchMessage struct {
-	Request  *url.URL
-	Response *http.Response
+	Request  *Request
+	Response *Response
 	Error    error
 }
 
+// URL returns the URL the Response was received from,
+// which differs from the URL of Request when it was
+// redirected
+func (m *FetchMessage) URL() *url.URL {
+	if m.Response != nil && m.Response.URL != nil {
+		return m.Response.URL
+	}
+	return m.Request.URL
+}
+
+// redirects returns the redirects that led
+// from Request to the Response, if any
+func (m *FetchMessage) redirects() []Redirect {
+	if m.Response == nil {
+		return nil
+	}
+	return m.Response.Redirects
+}
+
 // RequestQueue is used for incoming
 // requests to the fetcher
-type RequestQueue chan url.URL
+type RequestQueue chan *Request
 
 // FetchResponseQueue queue is used for outgoing
 // responses from the Fetcher
@@ -55,20 +87,38 @@ type AsyncHTTPFetcher struct {
 	requestQueue  *RequestQueue
 	responseQueue *FetchResponseQueue
 
-	client HTPPClient
+	client      HTPPClient
+	userAgent   string
+	concurrency int
+	limiter     *HostLimiter
+	retry       *RetryPolicy
+	middlewares middlewares
+
+	// Bodies larger than maxBodySize, if not
+	// 0, are dealt with as bodyLimit says
+	maxBodySize int64
+	bodyLimit   BodyLimit
 }
 
 // NewAsyncHTTPFetcher is a constructor for a
 // AsyncHTTPFetcher. It does not start the
 // Fetcher, which should be done by using the
-// Run method
-func NewAsyncHTTPFetcher() *AsyncHTTPFetcher {
-	reqQueue := make(RequestQueue, defaultChannelSize)
-	resQueue := make(FetchResponseQueue, defaultChannelSize)
+// Run method. concurrency is the number of
+// requests the Fetcher performs in parallel
+func NewAsyncHTTPFetcher(concurrency int) *AsyncHTTPFetcher {
+	return newAsyncHTTPFetcher(concurrency, defaultChannelSize)
+}
+
+// newAsyncHTTPFetcher is NewAsyncHTTPFetcher with
+// queues holding up to queueSize messages
+func newAsyncHTTPFetcher(concurrency, queueSize int) *AsyncHTTPFetcher {
+	reqQueue := make(RequestQueue, queueSize)
+	resQueue := make(FetchResponseQueue, queueSize)
 	a := &AsyncHTTPFetcher{
 		AsyncWorker: NewAsyncWorker("Fetcher"),
 
-		client:        &http.Client{},
+		client:        NewNetHTTPClient(NewHTTPClient()),
+		concurrency:   concurrency,
 		requestQueue:  &reqQueue,
 		responseQueue: &resQueue,
 	}
@@ -85,57 +135,175 @@ func (a *AsyncHTTPFetcher) Fetch(url *url.URL) error {
 		return fmt.Errorf("%s is in state stopped", a.AsyncWorker.Type())
 	}
 
-	if err := a.validate(url); err != nil {
+	if err := validateURL(url); err != nil {
 		return err
 	}
 
 	normURL, _ := util.NormalizeURL(url)
 	util.Printf("Fetcher: Adding URL %v to request queue\n", normURL)
-	*a.requestQueue <- *normURL
+	*a.requestQueue <- NewRequest(normURL)
 	return nil
 }
 
+// RequestChannel is a Getter returning the Fetcher's Channel that producers
+// can place requests on
+func (a *AsyncHTTPFetcher) RequestChannel() (requestQueue *RequestQueue) {
+	return a.requestQueue
+}
+
 // ResponseChannel is a Getter returning the Fetcher's Channel  that consumers
 // should be receiving results from
 func (a *AsyncHTTPFetcher) ResponseChannel() (responseQueue *FetchResponseQueue) {
 	return a.responseQueue
 }
 
+// SetRateLimiter provides the Fetcher with a HostLimiter
+// it consults before sending every request
+func (a *AsyncHTTPFetcher) SetRateLimiter(l *HostLimiter) {
+	a.limiter = l
+}
+
+// SetRetryPolicy provides the Fetcher with the
+// policy used to retry failed requests
+func (a *AsyncHTTPFetcher) SetRetryPolicy(p *RetryPolicy) {
+	a.retry = p
+}
+
+// SetMaxBodySize makes the Fetcher cut the bodies larger
+// than size bytes, or fail their page, as limit says
+func (a *AsyncHTTPFetcher) SetMaxBodySize(size int64, limit BodyLimit) {
+	a.maxBodySize = size
+	a.bodyLimit = limit
+}
+
+// Use adds m to the Middleware whose OnRequest
+// hooks are called before every page is requested
+func (a *AsyncHTTPFetcher) Use(m ...Middleware) {
+	a.middlewares = append(a.middlewares, m...)
+}
+
 // Worker Returns the embedded AsyncWorker struct
 // which is used to Run and Stop the fetcher worker
 func (a *AsyncHTTPFetcher) Worker() Worker {
 	return a.AsyncWorker
 }
 
-// Run starts a loop that waits for requests
-// or the quit signal. Run will be interrupted
-// once the Stop method is used
-func (a *AsyncHTTPFetcher) Run() error {
-	a.AsyncWorker.SetState(RUNNING)
+// Run starts a pool of goroutines that wait for
+// requests on the shared request queue. Run blocks
+// until ctx is done
+func (a *AsyncHTTPFetcher) Run(ctx context.Context) error {
+	a.AsyncWorker.SetState(WAITING)
+	return a.AsyncWorker.RunPool(ctx, a.concurrency, a.fetch)
+}
+
+// fetch is the loop run by every goroutine of the pool. It
+// returns once ctx is done, which cancels the request in
+// progress, if any
+func (a *AsyncHTTPFetcher) fetch(ctx context.Context) {
 	for {
-		a.AsyncWorker.SetState(WAITING)
 		select {
 
 		// A request is received
 		case req := <-*a.requestQueue:
-			a.AsyncWorker.SetState(RUNNING)
-			res, err := a.client.Get(req.String())
-			*a.responseQueue <- &FetchMessage{
-				Request:  &req,
+			a.AsyncWorker.markBusy()
+			res, err := a.get(ctx, req)
+			if err == nil {
+				err = redirectError(res)
+			}
+			if err == nil {
+				err = a.checkBody(res)
+			}
+			select {
+			case *a.responseQueue <- &FetchMessage{
+				Request:  req,
 				Response: res,
 				Error:    err,
+			}:
+			case <-ctx.Done():
 			}
+			a.AsyncWorker.markIdle()
 
-			// A quit has been received, Stop has been invoked
-		case <-a.AsyncWorker.Quit:
-			a.Worker().SetState(STOPPED)
-			return nil
-		default:
+			// The pool is shutting down
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func (a *AsyncHTTPFetcher) validate(uri *url.URL) error {
+// get sends req, retrying for as long as the RetryPolicy
+// allows. A request that still fails after its last attempt
+// returns an error giving the final cause of the failure. A
+// request vetoed by the OnRequest hooks returns their error,
+// and one cancelled along with ctx the error of ctx
+func (a *AsyncHTTPFetcher) get(ctx context.Context, req *Request) (*Response, error) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	if a.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", a.userAgent)
+	}
+	if req.MediaTypes == nil {
+		req.MediaTypes = htmlMediaTypes
+	}
+	if req.MaxBodySize == 0 {
+		req.MaxBodySize = a.maxBodySize
+	}
+	if err := a.middlewares.request(req); err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		release, err := a.limiter.AcquireContext(ctx, req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		res, err := a.client.Do(ctx, req)
+		release()
+		if res != nil {
+			res.Request = req
+			if res.URL == nil {
+				res.URL = req.URL
+			}
+		}
+
+		if a.retry == nil || !a.retry.Retryable(res, err) {
+			return res, err
+		}
+
+		delay, ok := a.retry.Delay(attempt, res)
+		if !ok {
+			if err != nil {
+				return nil, fmt.Errorf("giving up after %d attempts: %v", attempt, err)
+			}
+			return res, fmt.Errorf("giving up after %d attempts: %s", attempt, res.Status)
+		}
+
+		util.Printf("Fetcher: Retrying %v in %v (attempt %d)\n", req.URL, delay, attempt)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// checkBody cuts the body of res at the maximum body size, for
+// HTPPClients that do not. It returns an error if the body is
+// larger and the page is to be failed
+func (a *AsyncHTTPFetcher) checkBody(res *Response) error {
+	if a.maxBodySize > 0 && int64(len(res.Body)) > a.maxBodySize {
+		res.Body = res.Body[:a.maxBodySize]
+		res.Truncated = true
+	}
+	if res.Truncated && a.bodyLimit == AbortBody {
+		return fmt.Errorf("body larger than %d bytes", len(res.Body))
+	}
+	return nil
+}
+
+// validateURL returns an error for URLs
+// that cannot be fetched over HTTP
+func validateURL(uri *url.URL) error {
 	if uri.Scheme != "http" && uri.Scheme != "https" {
 		return fmt.Errorf("Unsupported uri scheme %s", uri.Scheme)
 	}
@@ -1,11 +1,12 @@
 package crawl
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,27 +18,42 @@ type mockHTTPClient struct {
 	mock.Mock
 }
 
-func (m *mockHTTPClient) Get(url string) (resp *http.Response, err error) {
-
+func (m *mockHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	url := req.URL.String()
 	if strings.Contains(url, "nonexistingwebsite") {
 		return nil, fmt.Errorf("no such host")
 	} else if strings.Contains(url, "404") {
-		return &http.Response{
+		return &Response{
 			Status: "404",
 		}, nil
 	}
 
-	response := &http.Response{
+	response := &Response{
 		Status: "200",
 	}
 	return response, nil
 }
 
+// blockingHTTPClient holds every request until release is
+// closed, recording the number of requests in flight
+type blockingHTTPClient struct {
+	inFlight chan struct{}
+	release  chan struct{}
+}
+
+func (m *blockingHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	m.inFlight <- struct{}{}
+	<-m.release
+	return &Response{
+		Status: "200",
+	}, nil
+}
+
 type FetchTestSuite struct {
 	suite.Suite
 }
 
-func NewTestFetcher() *AsyncHTTPFetcher {
+func NewTestFetcher() (*AsyncHTTPFetcher, func()) {
 	reqQueue := make(RequestQueue)
 	resQueue := make(FetchResponseQueue)
 	a := &AsyncHTTPFetcher{
@@ -48,19 +64,19 @@ func NewTestFetcher() *AsyncHTTPFetcher {
 		responseQueue: &resQueue,
 	}
 	a.AsyncWorker.RunFunc = a.Run
-	go a.AsyncWorker.Run()
-	return a
+	return a, runWorker(a.Worker())
 }
 
 func (suite *FetchTestSuite) TestFetchValidAndInvalidResponse() {
-	f := NewTestFetcher()
+	f, stop := NewTestFetcher()
+	defer stop()
 
 	// Valid Request
 	uri, _ := url.ParseRequestURI("https://validurl.com")
 	f.Fetch(uri)
 	m := <-*f.ResponseChannel()
 
-	assert.Equal(suite.T(), m.Request.String(), uri.String())
+	assert.Equal(suite.T(), m.Request.URL.String(), uri.String())
 	assert.NoError(suite.T(), m.Error)
 	assert.Equal(suite.T(), "200", m.Response.Status)
 
@@ -85,10 +101,41 @@ func (suite *FetchTestSuite) TestFetchValidAndInvalidResponse() {
 
 }
 
+func (suite *FetchTestSuite) TestConcurrentFetches() {
+	client := &blockingHTTPClient{
+		inFlight: make(chan struct{}, 3),
+		release:  make(chan struct{}),
+	}
+	f := NewAsyncHTTPFetcher(3)
+	f.client = client
+	stop := runWorker(f.Worker())
+
+	for _, u := range []string{"http://a.com", "http://b.com", "http://c.com"} {
+		uri, _ := url.ParseRequestURI(u)
+		f.Fetch(uri)
+	}
+
+	// All three requests are in flight at the same time
+	for i := 0; i < 3; i++ {
+		select {
+		case <-client.inFlight:
+		case <-time.After(time.Second):
+			suite.T().Fatalf("only %d requests in flight", i)
+		}
+	}
+
+	close(client.release)
+	for i := 0; i < 3; i++ {
+		m := <-*f.ResponseChannel()
+		assert.NoError(suite.T(), m.Error)
+	}
+	stop()
+}
+
 func (suite *FetchTestSuite) TestStopFetcher() {
-	f := NewTestFetcher()
+	f, stop := NewTestFetcher()
 	assert.Equal(suite.T(), WAITING, f.Worker().State())
-	f.Stop()
+	stop()
 	assert.Equal(suite.T(), STOPPED, f.Worker().State())
 
 	uri, _ := url.ParseRequestURI("https://validurl.com")
@@ -98,7 +145,7 @@ func (suite *FetchTestSuite) TestStopFetcher() {
 }
 
 func (suite *FetchTestSuite) TestNewAsyncHTTPFetcherConstructor() {
-	f := NewAsyncHTTPFetcher()
+	f := NewAsyncHTTPFetcher(DefaultConcurrency)
 	assert.Implements(suite.T(), (*Fetcher)(nil), f)
 	assert.NotNil(suite.T(), f)
 }
@@ -1,34 +1,208 @@
 package crawl
 
-import "net/http"
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
 
-// HTPPClient interface that wraps around the http.Client
-// struct and can be replaced by any other client implementation
+// HTPPClient sends the Requests of a crawl. It hands back
+// Responses whose body has been read, the body of the
+// underlying response being closed by then
 type HTPPClient interface {
-	// At the moment, response is of type http.Response which locks
-	// in implementation!
-	Get(url string) (resp *http.Response, err error)
-}
-
-// Responder interface encapsulates the needed
-// behaviour of http.Response. Rather than using
-// the http.Response struct, the consumers use this
-// interface instead
-// type Responder interface {
-//
-// 	// Body of the Response
-// 	BodyB() *[]byte
-//
-// 	// Status of the Response
-// 	Status() string
-// }
-
-// type Response struct {
-// 	*http.Response
-// }
-//
-// func (r *Response) BodyB() *[]byte {
-//
-// 	r.Body.Read(p)
-//
-// }
+	Do(ctx context.Context, req *Request) (*Response, error)
+}
+
+// Request is a request for the page at URL, found Depth
+// clicks away from the seed in the page at Referrer, which
+// is nil for a seed. Requests of a higher Priority leave
+// the frontier first
+type Request struct {
+	Method   string
+	URL      *url.URL
+	Header   http.Header
+	Depth    int
+	Referrer *url.URL
+	Priority int
+
+	// MediaTypes, if not empty, are the media types whose
+	// body is wanted. The body of a response declaring
+	// another one in its Content-Type is not read, while
+	// that of a response declaring none, or only
+	// application/octet-stream, is read as far as needed
+	// to sniff its media type
+	MediaTypes []string
+
+	// MaxBodySize, if not 0, is the size the body
+	// of the response is cut at, if the HTPPClient
+	// does not cut it shorter
+	MaxBodySize int64
+}
+
+// A PriorityFunc returns the Priority of a Request
+// found in the crawl, before it joins the frontier
+type PriorityFunc func(req *Request) int
+
+// NewRequest returns a GET Request for u
+func NewRequest(u *url.URL) *Request {
+	return &Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: make(http.Header),
+	}
+}
+
+// Response is the response to Request
+type Response struct {
+	Request *Request
+
+	// URL is the URL of the response,
+	// which Redirects led to from Request
+	URL       *url.URL
+	Redirects []Redirect
+
+	Status     string
+	StatusCode int
+	Header     http.Header
+
+	// MediaType is the media type of the body,
+	// as declared or sniffed, if known
+	MediaType string
+
+	// Body is the body of the response. It is cut at the
+	// size limit of the Request or of the HTPPClient if
+	// Truncated is set, and is nil if it was not wanted
+	Body      []byte
+	Truncated bool
+
+	Timings Timings
+}
+
+// Timings tell how long a request took
+type Timings struct {
+	// Start is the time the request was sent at
+	Start time.Time
+
+	// Header is the time it took to get the headers of the
+	// response, and Total the time it took to read its body
+	Header time.Duration
+	Total  time.Duration
+}
+
+// NetHTTPClient is an HTPPClient sending requests with an
+// http.Client. Bodies are read up to MaxBodySize bytes, or
+// whole if neither MaxBodySize nor the Request set a limit
+type NetHTTPClient struct {
+	Client      *http.Client
+	MaxBodySize int64
+}
+
+// NewNetHTTPClient is a NetHTTPClient constructor. The
+// redirects followed are recorded by the http.Client returned
+// by NewHTTPClient, while other http.Clients need a
+// CheckRedirect hook that does not follow them for the
+// chain to be complete
+func NewNetHTTPClient(c *http.Client) *NetHTTPClient {
+	return &NetHTTPClient{Client: c}
+}
+
+// NewHTTPClient returns an http.Client following redirects
+// until they loop or until there are too many of them, the
+// last redirect being returned as the response then
+func NewHTTPClient() *http.Client {
+	return &http.Client{CheckRedirect: checkRedirect}
+}
+
+// Do sends req, cancelling it along with ctx, and reads the
+// body of the response before closing it
+func (c *NetHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	hreq, err := http.NewRequestWithContext(ctx, method, req.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Header {
+		hreq.Header[k] = v
+	}
+
+	start := time.Now()
+	res, err := c.Client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	r := &Response{
+		Request:    req,
+		URL:        req.URL,
+		Redirects:  redirectChain(res),
+		Status:     res.Status,
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Timings:    Timings{Start: start, Header: time.Since(start)},
+	}
+	if n := len(r.Redirects); n > 0 {
+		r.URL = r.Redirects[n-1].To
+	}
+
+	r.Body, r.Truncated, r.MediaType, err = readWanted(res, req.MediaTypes,
+		minBodySize(c.MaxBodySize, req.MaxBodySize))
+	r.Timings.Total = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// minBodySize returns the lowest of two body size
+// limits, 0 standing for no limit
+func minBodySize(a, b int64) int64 {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// readWanted reads the body of res up to max bytes if its media
+// type is one of mediaTypes, returning the body, whether it was
+// truncated and the media type. Bodies of an unknown media type
+// are read up to sniffLen bytes first, for it to be sniffed
+func readWanted(res *http.Response, mediaTypes []string, max int64) ([]byte, bool, string, error) {
+	declared := declaredMediaType(res.Header)
+	if len(mediaTypes) == 0 || hasMediaType(mediaTypes, declared) {
+		body, truncated, err := readBody(res.Body, max)
+		return body, truncated, MediaType(res.Header, body), err
+	}
+	if declared != "" && declared != "application/octet-stream" {
+		return nil, false, declared, nil
+	}
+
+	head, err := ioutil.ReadAll(io.LimitReader(res.Body, sniffLen))
+	mediaType := MediaType(res.Header, head)
+	if err != nil || !hasMediaType(mediaTypes, mediaType) {
+		return nil, false, mediaType, err
+	}
+	body, truncated, err := readBody(io.MultiReader(bytes.NewReader(head), res.Body), max)
+	return body, truncated, mediaType, err
+}
+
+// readBody reads body up to max bytes, or whole if max is 0,
+// returning whether it was truncated
+func readBody(body io.Reader, max int64) ([]byte, bool, error) {
+	if max <= 0 {
+		b, err := ioutil.ReadAll(body)
+		return b, false, err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(body, max+1))
+	if int64(len(b)) > max {
+		return b[:max], true, err
+	}
+	return b, false, err
+}
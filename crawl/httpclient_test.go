@@ -0,0 +1,141 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// closeRecordingTransport is an http.RoundTripper
+// counting the response bodies left open
+type closeRecordingTransport struct {
+	open int
+}
+
+type recordedBody struct {
+	io.ReadCloser
+	t *closeRecordingTransport
+}
+
+func (b *recordedBody) Close() error {
+	b.t.open--
+	return b.ReadCloser.Close()
+}
+
+func (t *closeRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.open++
+	res.Body = &recordedBody{ReadCloser: res.Body, t: t}
+	return res, nil
+}
+
+type HTTPClientTestSuite struct {
+	suite.Suite
+	server    *httptest.Server
+	transport *closeRecordingTransport
+	client    *NetHTTPClient
+}
+
+func (suite *HTTPClientTestSuite) SetupTest() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User-Agent", r.Header.Get("User-Agent"))
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	})
+	mux.HandleFunc("/octets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, "<html><body>"+strings.Repeat("a", 1000)+"</body></html>")
+	})
+	mux.Handle("/old", http.RedirectHandler("/", http.StatusMovedPermanently))
+	suite.server = httptest.NewServer(mux)
+
+	suite.transport = &closeRecordingTransport{}
+	c := NewHTTPClient()
+	c.Transport = suite.transport
+	suite.client = NewNetHTTPClient(c)
+}
+
+func (suite *HTTPClientTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *HTTPClientTestSuite) request(path string) *Request {
+	u, _ := url.ParseRequestURI(suite.server.URL + path)
+	return NewRequest(u)
+}
+
+func (suite *HTTPClientTestSuite) TestDo() {
+	req := suite.request("/old")
+	req.Header.Set("User-Agent", "test-agent")
+	res, err := suite.client.Do(context.Background(), req)
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), req, res.Request)
+	assert.Equal(suite.T(), suite.server.URL+"/", res.URL.String())
+	assert.Len(suite.T(), res.Redirects, 1)
+	assert.Equal(suite.T(), http.StatusOK, res.StatusCode)
+	assert.Equal(suite.T(), "test-agent", res.Header.Get("X-User-Agent"))
+	assert.Len(suite.T(), res.Body, 100)
+	assert.False(suite.T(), res.Truncated)
+	assert.False(suite.T(), res.Timings.Start.IsZero())
+	assert.True(suite.T(), res.Timings.Total >= res.Timings.Header)
+
+	// The bodies of the redirect and of the page are closed
+	assert.Equal(suite.T(), 0, suite.transport.open)
+}
+
+func (suite *HTTPClientTestSuite) TestMaxBodySize() {
+	suite.client.MaxBodySize = 10
+	res, err := suite.client.Do(context.Background(), suite.request("/"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []byte(strings.Repeat("a", 10)), res.Body)
+	assert.True(suite.T(), res.Truncated)
+	assert.Equal(suite.T(), 0, suite.transport.open)
+
+	suite.client.MaxBodySize = 100
+	res, err = suite.client.Do(context.Background(), suite.request("/"))
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), res.Body, 100)
+	assert.False(suite.T(), res.Truncated)
+}
+
+func (suite *HTTPClientTestSuite) TestSniffedMediaType() {
+	// The whole body of an HTML page served as octets is read
+	req := suite.request("/octets")
+	req.MediaTypes = htmlMediaTypes
+	res, err := suite.client.Do(context.Background(), req)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "text/html", res.MediaType)
+	assert.Len(suite.T(), res.Body, 1026)
+	assert.Equal(suite.T(), 0, suite.transport.open)
+
+	// The body of anything else is not
+	req = suite.request("/")
+	req.MediaTypes = []string{"image/png"}
+	res, err = suite.client.Do(context.Background(), req)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "text/plain", res.MediaType)
+	assert.Nil(suite.T(), res.Body)
+}
+
+func (suite *HTTPClientTestSuite) TestCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := suite.client.Do(ctx, suite.request("/"))
+	assert.Error(suite.T(), err)
+}
+
+func TestHTTPClientTestSuite(t *testing.T) {
+	suite.Run(t, new(HTTPClientTestSuite))
+}
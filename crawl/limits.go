@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultGracePeriod is the time given to the pages being
+// crawled to be done once the crawl is interrupted
+const DefaultGracePeriod = 10 * time.Second
+
+// Limits bound a crawl. The Tracker stops passing URLs on
+// to the Fetcher once one of them but MaxDepth is reached,
+// and the crawl ends when the pages already passed on are
+// done. Zero values disable the respective limit
+type Limits struct {
+	// MaxDepth is the number of clicks from the seed
+	// beyond which links are not followed, the crawl
+	// going on with the links closer to the seed
+	MaxDepth int
+
+	// MaxPages is the number of pages crawled
+	MaxPages int
+
+	// MaxBytes is the size of the pages crawled, in bytes
+	MaxBytes int64
+
+	// MaxDuration is the time the crawl may take
+	MaxDuration time.Duration
+}
+
+// depth returns a description of the MaxDepth limit
+// if a page at depth is beyond it, or ""
+func (l Limits) depth(depth int) string {
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Sprintf("max depth (%d)", l.MaxDepth)
+	}
+	return ""
+}
+
+// pages returns a description of the MaxPages limit
+// if it is reached by the pages crawled, or ""
+func (l Limits) pages(pages int) string {
+	if l.MaxPages > 0 && pages >= l.MaxPages {
+		return fmt.Sprintf("max pages (%d)", l.MaxPages)
+	}
+	return ""
+}
+
+// bytes returns a description of the MaxBytes limit
+// if it is reached by the bytes crawled, or ""
+func (l Limits) bytes(bytes int64) string {
+	if l.MaxBytes > 0 && bytes >= l.MaxBytes {
+		return fmt.Sprintf("max bytes (%d)", l.MaxBytes)
+	}
+	return ""
+}
+
+// duration returns a description of the MaxDuration limit
+func (l Limits) duration() string {
+	return fmt.Sprintf("max duration (%v)", l.MaxDuration)
+}
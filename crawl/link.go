@@ -0,0 +1,254 @@
+package crawl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"golang.org/x/net/html"
+)
+
+// LinkKind is the kind of resource a link of a page points to
+type LinkKind string
+
+// Possible LinkKinds
+const (
+	// NavigationLink: another page, as linked to by <a>, <area> or <link>
+	NavigationLink LinkKind = "navigation"
+	// StylesheetLink: a <link rel="stylesheet">
+	StylesheetLink LinkKind = "stylesheet"
+	// ScriptLink: a <script src>
+	ScriptLink LinkKind = "script"
+	// ImageLink: an image, such as those of <img src/srcset> or <video poster>
+	ImageLink LinkKind = "image"
+	// MediaLink: audio, video or embedded content
+	MediaLink LinkKind = "media"
+	// FrameLink: a page embedded with <iframe> or <frame>
+	FrameLink LinkKind = "frame"
+	// FormLink: the action of a form
+	FormLink LinkKind = "form"
+	// ResourceLink: another resource a <link> points to,
+	// such as a font, a web app manifest or a feed
+	ResourceLink LinkKind = "resource"
+)
+
+// LinkKinds are all the LinkKinds
+var LinkKinds = []LinkKind{
+	NavigationLink, StylesheetLink, ScriptLink,
+	ImageLink, MediaLink, FrameLink, FormLink, ResourceLink,
+}
+
+// ParseLinkKind returns the LinkKind named s
+func ParseLinkKind(s string) (LinkKind, error) {
+	for _, k := range LinkKinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown link kind %q", s)
+}
+
+// Link is a link of kind Kind to URL found in a page.
+// Nofollow is set when the link, or the page, is
+// marked as not to be followed
+type Link struct {
+	URL      *url.URL
+	Kind     LinkKind
+	Nofollow bool
+}
+
+// LinkAction is what is done with the links of a LinkKind
+type LinkAction int
+
+// Possible LinkActions
+const (
+	// IgnoreLink drops the links
+	IgnoreLink LinkAction = iota
+	// RecordLink adds the links to the sitemap without crawling them
+	RecordLink
+	// CrawlLink adds the links to the sitemap and crawls them
+	CrawlLink
+)
+
+// LinkPolicy tells what is done with the links of every
+// LinkKind. The links of the kinds missing are ignored
+type LinkPolicy map[LinkKind]LinkAction
+
+// DefaultLinkPolicy returns the LinkPolicy crawling
+// navigation links and frames, and ignoring the others
+func DefaultLinkPolicy() LinkPolicy {
+	return LinkPolicy{
+		NavigationLink: CrawlLink,
+		FrameLink:      CrawlLink,
+	}
+}
+
+var defaultLinkPolicy = DefaultLinkPolicy()
+
+// action returns what is done with links of kind k.
+// A nil LinkPolicy is the DefaultLinkPolicy
+func (p LinkPolicy) action(k LinkKind) LinkAction {
+	if p == nil {
+		p = defaultLinkPolicy
+	}
+	return p[k]
+}
+
+// edgeKind returns the kind of the edge of
+// the sitemap a link of kind k is added as
+func edgeKind(k LinkKind) sitemap.EdgeKind {
+	if k == NavigationLink {
+		return sitemap.LinkEdge
+	}
+	return sitemap.EdgeKind(k)
+}
+
+// linkAttr is an attribute of an element
+// holding a link of the given kind
+type linkAttr struct {
+	name string
+	kind LinkKind
+}
+
+// linkAttrs lists the attributes holding links, by element
+var linkAttrs = map[string][]linkAttr{
+	"a":      {{"href", NavigationLink}},
+	"area":   {{"href", NavigationLink}},
+	"link":   {{"href", NavigationLink}},
+	"script": {{"src", ScriptLink}},
+	"img":    {{"src", ImageLink}, {"srcset", ImageLink}},
+	"input":  {{"src", ImageLink}, {"formaction", FormLink}},
+	"source": {{"src", MediaLink}, {"srcset", ImageLink}},
+	"video":  {{"src", MediaLink}, {"poster", ImageLink}},
+	"audio":  {{"src", MediaLink}},
+	"track":  {{"src", MediaLink}},
+	"embed":  {{"src", MediaLink}},
+	"object": {{"data", MediaLink}},
+	"iframe": {{"src", FrameLink}},
+	"frame":  {{"src", FrameLink}},
+	"form":   {{"action", FormLink}},
+	"button": {{"formaction", FormLink}},
+}
+
+// rawLink is a link as found in a page, before it is resolved
+type rawLink struct {
+	href     string
+	kind     LinkKind
+	nofollow bool
+}
+
+// tokenLinks returns the links held by the attributes of t.
+// The kind of a <link> depends on its rel, <link>s to
+// origins rather than resources being left out
+func tokenLinks(t html.Token) []rawLink {
+	var links []rawLink
+	nofollow := (t.Data == "a" || t.Data == "area") && hasToken(getAttr(t, "rel"), "nofollow")
+	for _, attr := range linkAttrs[t.Data] {
+		kind, ok := attr.kind, true
+		if t.Data == "link" {
+			if kind, ok = relKind(t); !ok {
+				continue
+			}
+		}
+		val, ok := lookupAttr(t, attr.name)
+		if !ok {
+			continue
+		}
+		if attr.name == "srcset" {
+			for _, href := range srcset(val) {
+				links = append(links, rawLink{href: href, kind: kind})
+			}
+			continue
+		}
+		links = append(links, rawLink{href: val, kind: kind, nofollow: nofollow})
+	}
+	return links
+}
+
+// navigationRels are the rels of the <link>s to other pages
+var navigationRels = map[string]bool{
+	"alternate": true, "canonical": true, "amphtml": true, "next": true,
+	"prev": true, "previous": true, "author": true, "help": true,
+	"license": true, "prerender": true,
+}
+
+// feedMediaTypes are the media types of feeds,
+// which <link rel="alternate"> also points to
+var feedMediaTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json"}
+
+// relKind returns the kind of t, a <link>, as told by its rel,
+// and false for those pointing to origins to connect to. The
+// resources preloaded or prefetched are of the kind their as
+// attribute tells, and <link>s of an unknown rel are resources
+func relKind(t html.Token) (LinkKind, bool) {
+	feed := hasMediaType(feedMediaTypes, strings.ToLower(strings.TrimSpace(getAttr(t, "type"))))
+	kind := ResourceLink
+	for _, r := range strings.Fields(strings.ToLower(getAttr(t, "rel"))) {
+		switch {
+		case r == "stylesheet":
+			return StylesheetLink, true
+		case r == "dns-prefetch" || r == "preconnect":
+			return "", false
+		case r == "preload" || r == "prefetch":
+			return asKind(getAttr(t, "as"), ResourceLink), true
+		case r == "modulepreload":
+			return asKind(getAttr(t, "as"), ScriptLink), true
+		case strings.Contains(r, "icon"):
+			kind = ImageLink
+		case navigationRels[r] && !feed && kind == ResourceLink:
+			kind = NavigationLink
+		}
+	}
+	return kind, true
+}
+
+// asKind returns the kind of a resource preloaded with the
+// given as attribute, or def if the attribute is missing
+func asKind(as string, def LinkKind) LinkKind {
+	switch strings.ToLower(strings.TrimSpace(as)) {
+	case "":
+		return def
+	case "document":
+		return NavigationLink
+	case "script", "worker", "sharedworker", "serviceworker", "audioworklet", "paintworklet":
+		return ScriptLink
+	case "style":
+		return StylesheetLink
+	case "image":
+		return ImageLink
+	case "audio", "video", "track":
+		return MediaLink
+	}
+	return ResourceLink
+}
+
+// srcset returns the URLs of the image
+// candidates of a srcset attribute
+func srcset(val string) []string {
+	urls := make([]string, 0)
+	for _, candidate := range strings.Split(val, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// lookupAttr returns the value of the attribute
+// key of t, and whether t has one
+func lookupAttr(t html.Token, key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// getAttr returns the value of the attribute
+// key of t, or "" if it has none
+func getAttr(t html.Token, key string) string {
+	val, _ := lookupAttr(t, key)
+	return val
+}
@@ -0,0 +1,175 @@
+package crawl
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/html"
+)
+
+var linkSite = map[string]string{
+	"http://example.com": `
+		<html><head>
+		<link rel="stylesheet" href="/style.css">
+		<link rel="icon" href="/favicon.ico">
+		<link rel="alternate" href="/fr/">
+		<link rel="preconnect" href="https://cdn.example.com">
+		<script src="/app.js"></script>
+		</head><body>
+		<a href="/about">About</a>
+		<img src="/logo.png" srcset="/logo-2x.png 2x, /logo-3x.png 3x"/>
+		<map><area href="/map"></map>
+		<video src="/intro.mp4" poster="/intro.jpg"><track src="/intro.vtt"></video>
+		<picture><source srcset="/hero.webp"></picture>
+		<iframe src="/embedded"></iframe>
+		<form action="/search"><button formaction="/advanced">Go</button></form>
+		<object data="/doc.swf"></object>
+		</body></html>`,
+	"http://example.com/about":     `<a href="/logo.png">Logo</a>`,
+	"http://example.com/style.css": `body { background: url(/bg.png) }`,
+}
+
+type LinkTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *LinkTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+func (suite *LinkTestSuite) TestLinkKinds() {
+	p := NewAsyncHTTPParser(suite.seedURL, NewMockFetcher(), 1)
+	everything := LinkPolicy{}
+	for _, k := range LinkKinds {
+		everything[k] = CrawlLink
+	}
+	p.SetLinkPolicy(everything)
+
+	links, _ := p.extractLinks(&FetchMessage{
+		Request:  NewRequest(suite.seedURL),
+		Response: &Response{Body: []byte(linkSite["http://example.com"])},
+	})
+	kinds := make(map[string]LinkKind)
+	for _, l := range links {
+		kinds[l.URL.Path] = l.Kind
+	}
+	assert.Equal(suite.T(), map[string]LinkKind{
+		"/style.css":   StylesheetLink,
+		"/favicon.ico": ImageLink,
+		"/fr/":         NavigationLink,
+		"/app.js":      ScriptLink,
+		"/about":       NavigationLink,
+		"/logo.png":    ImageLink,
+		"/logo-2x.png": ImageLink,
+		"/logo-3x.png": ImageLink,
+		"/map":         NavigationLink,
+		"/intro.mp4":   MediaLink,
+		"/intro.jpg":   ImageLink,
+		"/intro.vtt":   MediaLink,
+		"/hero.webp":   ImageLink,
+		"/embedded":    FrameLink,
+		"/search":      FormLink,
+		"/advanced":    FormLink,
+		"/doc.swf":     MediaLink,
+	}, kinds)
+
+	// The links of the kinds ignored are left out
+	p.SetLinkPolicy(nil)
+	links, _ = p.extractLinks(&FetchMessage{
+		Request:  NewRequest(suite.seedURL),
+		Response: &Response{Body: []byte(linkSite["http://example.com"])},
+	})
+	assert.Equal(suite.T(), []string{
+		"http://example.com/fr/",
+		"http://example.com/about",
+		"http://example.com/map",
+		"http://example.com/embedded",
+	}, linkStrings(links))
+}
+
+func (suite *LinkTestSuite) TestRelKind() {
+	for _, test := range []struct {
+		link string
+		kind LinkKind
+	}{
+		{`<link rel="stylesheet" href="/a">`, StylesheetLink},
+		{`<link rel="alternate stylesheet" href="/a">`, StylesheetLink},
+		{`<link rel="alternate" hreflang="fr" href="/a">`, NavigationLink},
+		{`<link rel="next" href="/a">`, NavigationLink},
+		{`<link rel="alternate" type="application/rss+xml" href="/a">`, ResourceLink},
+		{`<link rel="alternate" type="Application/Atom+XML" href="/a">`, ResourceLink},
+		{`<link rel="shortcut icon" href="/a">`, ImageLink},
+		{`<link rel="apple-touch-icon-precomposed" href="/a">`, ImageLink},
+		{`<link rel="mask-icon" href="/a">`, ImageLink},
+		{`<link rel="manifest" href="/a">`, ResourceLink},
+		{`<link rel="preload" as="style" href="/a">`, StylesheetLink},
+		{`<link rel="preload" as="script" href="/a">`, ScriptLink},
+		{`<link rel="preload" as="image" href="/a">`, ImageLink},
+		{`<link rel="preload" as="video" href="/a">`, MediaLink},
+		{`<link rel="preload" as="font" crossorigin href="/a">`, ResourceLink},
+		{`<link rel="preload" href="/a">`, ResourceLink},
+		{`<link rel="modulepreload" href="/a">`, ScriptLink},
+		{`<link rel="prefetch" href="/a">`, ResourceLink},
+		{`<link rel="prefetch" as="document" href="/a">`, NavigationLink},
+		{`<link rel="pingback" href="/a">`, ResourceLink},
+		{`<link href="/a">`, ResourceLink},
+	} {
+		z := html.NewTokenizer(strings.NewReader(test.link))
+		z.Next()
+		kind, ok := relKind(z.Token())
+		assert.True(suite.T(), ok, test.link)
+		assert.Equal(suite.T(), test.kind, kind, test.link)
+	}
+
+	z := html.NewTokenizer(strings.NewReader(`<link rel="dns-prefetch" href="//cdn.example.com">`))
+	z.Next()
+	_, ok := relKind(z.Token())
+	assert.False(suite.T(), ok)
+}
+
+func (suite *LinkTestSuite) TestParseLinkKind() {
+	k, err := ParseLinkKind("image")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), ImageLink, k)
+	_, err = ParseLinkKind("font")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *LinkTestSuite) TestLinkPolicy() {
+	c := NewTestCrawler(&siteHTTPClient{pages: linkSite}, 2, 2)
+	c.SetLinkPolicy(LinkPolicy{
+		NavigationLink: CrawlLink,
+		StylesheetLink: CrawlLink,
+		ImageLink:      RecordLink,
+	})
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// Links recorded are in the sitemap, but not requested
+	pages := <-collected
+	assert.Contains(suite.T(), pages, "http://example.com/style.css")
+	assert.NotContains(suite.T(), pages, "http://example.com/favicon.ico")
+	assert.NotContains(suite.T(), pages, "http://example.com/app.js")
+	assert.Equal(suite.T(), sitemap.EdgeKind("stylesheet"),
+		stmp.EdgeKind("http://example.com", "http://example.com/style.css"))
+	assert.Equal(suite.T(), sitemap.EdgeKind("image"),
+		stmp.EdgeKind("http://example.com", "http://example.com/logo.png"))
+	assert.Equal(suite.T(), sitemap.EdgeKind(""),
+		stmp.EdgeKind("http://example.com", "http://example.com/app.js"))
+
+	// An image recorded is crawled when linked to
+	assert.Contains(suite.T(), pages, "http://example.com/logo.png")
+	assert.Equal(suite.T(), sitemap.LinkEdge,
+		stmp.EdgeKind("http://example.com/about", "http://example.com/logo.png"))
+}
+
+func TestLinkTestSuite(t *testing.T) {
+	suite.Run(t, new(LinkTestSuite))
+}
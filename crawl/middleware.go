@@ -0,0 +1,82 @@
+package crawl
+
+import "net/url"
+
+// Middleware hooks custom logic into the stages of the crawl.
+// Any of its hooks can be nil. Hooks are called by the workers
+// of the crawl, so from several goroutines at the same time
+type Middleware struct {
+	// OnRequest is called by the Fetcher before a page is
+	// requested. It can change the headers of req, or veto
+	// the request by returning an error, which the page
+	// then fails with
+	OnRequest func(req *Request) error
+
+	// OnResponse is called by the Parser before the page in
+	// res is parsed. It can inspect res, or veto parsing it
+	// by returning an error, which the page then fails with
+	OnResponse func(res *Response) error
+
+	// OnLink is called by the Parser for every link found in
+	// the page at page, before it is canonicalized and checked
+	// against the Scope. It returns the link to follow, which
+	// can be rewritten, or nil to drop it
+	OnLink func(page, link *url.URL) *url.URL
+
+	// OnPage is called by the Parser with
+	// the PageResult of every page parsed
+	OnPage func(r PageResult)
+}
+
+// middlewares is a chain of Middleware,
+// whose hooks are called in order
+type middlewares []Middleware
+
+// request calls the OnRequest hooks until one vetoes req
+func (m middlewares) request(req *Request) error {
+	for _, mw := range m {
+		if mw.OnRequest == nil {
+			continue
+		}
+		if err := mw.OnRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// response calls the OnResponse hooks until one vetoes res
+func (m middlewares) response(res *Response) error {
+	for _, mw := range m {
+		if mw.OnResponse == nil {
+			continue
+		}
+		if err := mw.OnResponse(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// link passes link through the OnLink hooks,
+// returning nil as soon as one drops it
+func (m middlewares) link(page, link *url.URL) *url.URL {
+	for _, mw := range m {
+		if mw.OnLink == nil {
+			continue
+		}
+		if link = mw.OnLink(page, link); link == nil {
+			return nil
+		}
+	}
+	return link
+}
+
+// page calls the OnPage hooks
+func (m middlewares) page(r PageResult) {
+	for _, mw := range m {
+		if mw.OnPage != nil {
+			mw.OnPage(r)
+		}
+	}
+}
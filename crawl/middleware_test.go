@@ -0,0 +1,129 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MiddlewareTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *MiddlewareTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+func (suite *MiddlewareTestSuite) TestOnRequest() {
+	var mutex sync.Mutex
+	requested := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested[r.URL.Path] = r.Header.Get("Authorization")
+		mutex.Unlock()
+		fmt.Fprint(w, `<a href="/public">Public</a><a href="/private">Private</a>`)
+	}))
+	defer server.Close()
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewCrawler(WithMiddleware(Middleware{
+		OnRequest: func(req *Request) error {
+			if req.URL.Path == "/private" {
+				return fmt.Errorf("private")
+			}
+			req.Header.Set("Authorization", "Bearer token")
+			return nil
+		},
+	}))
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The request vetoed is never sent, and fails
+	assert.Equal(suite.T(), map[string]string{"/": "Bearer token", "/public": "Bearer token"}, requested)
+	assert.EqualError(suite.T(), c.Report().Failures[server.URL+"/private"], "private")
+}
+
+func (suite *MiddlewareTestSuite) TestOnResponse() {
+	var pages []string
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 1, 1)
+	c.parser.Use(Middleware{
+		OnResponse: func(res *Response) error {
+			pages = append(pages, res.URL.String())
+			if res.URL.Path == "/news" {
+				return fmt.Errorf("not parsing the news")
+			}
+			return nil
+		},
+	})
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The links of the page vetoed are not followed
+	assert.ElementsMatch(suite.T(), []string{
+		"http://example.com",
+		"http://example.com/about",
+		"http://example.com/news",
+		"http://example.com/missing",
+	}, pages)
+	assert.Empty(suite.T(), *stmp.LinksFrom("http://example.com/news"))
+	assert.EqualError(suite.T(), c.Report().Failures["http://example.com/news"], "not parsing the news")
+}
+
+func (suite *MiddlewareTestSuite) TestOnLink() {
+	site := map[string]string{
+		"http://example.com": `
+			<a href="/old">Old</a>
+			<a href="/logout">Log out</a>
+			<a href="/about">About</a>`,
+	}
+	rewrite := Middleware{
+		OnLink: func(page, link *url.URL) *url.URL {
+			if link.Path == "/old" {
+				link.Path = "/new"
+			}
+			return link
+		},
+	}
+	drop := Middleware{
+		OnLink: func(page, link *url.URL) *url.URL {
+			if link.Path == "/logout" {
+				return nil
+			}
+			return link
+		},
+	}
+	c := NewCrawler(WithClient(&siteHTTPClient{pages: site}), WithMiddleware(rewrite, drop))
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"http://example.com/new", "http://example.com/about"},
+		*stmp.LinksFrom("http://example.com"))
+}
+
+func (suite *MiddlewareTestSuite) TestOnPage() {
+	var mutex sync.Mutex
+	depths := make(map[string]int)
+	c := NewCrawler(WithClient(&siteHTTPClient{pages: testSite}), WithParsers(2),
+		WithMiddleware(Middleware{
+			OnPage: func(r PageResult) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				depths[r.URL.String()] = r.Depth
+			},
+		}))
+	_, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), depths, 6)
+	assert.Equal(suite.T(), 2, depths["http://example.com/news/2"])
+}
+
+func TestMiddlewareTestSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareTestSuite))
+}
@@ -1,11 +1,12 @@
 package crawl
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"context"
 	"net/url"
 	"strings"
 
+	"github.com/antoniou/go-crawler/sitemap"
 	"github.com/antoniou/go-crawler/util"
 	"golang.org/x/net/html"
 )
@@ -30,63 +31,268 @@ type AsyncHTTPParser struct {
 	fetcher             Fetcher
 	parserResponseQueue *parserResponseQueue
 	seed                *url.URL
+	scope               Scope
+	canonicalizer       *util.Canonicalizer
+	concurrency         int
+	logger              Logger
+
+	// results, if not nil, receives the
+	// PageResult of every page parsed
+	results     chan<- PageResult
+	middlewares middlewares
+
+	// links tells which kinds of links are passed on
+	links LinkPolicy
 }
 
+// ParseMessage is passed from the Parser to the Tracker
+// about the page requested at Request, Depth clicks away
+// from the seed. It either carries
+//   - a link (Response) of kind Kind found in the page,
+//     which Nofollow marks as not to be followed
+//   - the Redirects that led from Request to the page at
+//     Response, sent before any link of the page
+//   - or, when Done is set, the signal that the page has been
+//     completely processed. Bytes, MediaType, Page, Directives
+//     and Canonical are then the size, media type, metadata,
+//     robots directives and canonical URL of the page, and
+//     Error is set if the page could not be fetched
 type ParseMessage struct {
-	Request  *url.URL
-	Response *url.URL
+	Request    *url.URL
+	Depth      int
+	Response   *url.URL
+	Kind       LinkKind
+	Nofollow   bool
+	Redirects  []Redirect
+	Done       bool
+	Bytes      int64
+	MediaType  string
+	Page       sitemap.Page
+	Directives []sitemap.Directive
+	Canonical  *url.URL
+	Error      error
 }
 
-func NewAsyncHTTPParser(seedURL *url.URL, fetcher Fetcher) *AsyncHTTPParser {
-	resQueue := make(parserResponseQueue, defaultChannelSize)
+// NewAsyncHTTPParser is a constructor for a AsyncHTTPParser.
+// concurrency is the number of pages parsed in parallel, all
+// of them consuming the Fetcher's ResponseChannel and sharing
+// the Parser's ResponseChannel. Only the links within the
+// DefaultScope of seedURL are passed on, unless another
+// Scope is set with SetScope. Without a seedURL, every
+// link is passed on until a Scope is set
+func NewAsyncHTTPParser(seedURL *url.URL, fetcher Fetcher, concurrency int) *AsyncHTTPParser {
+	return newAsyncHTTPParser(seedURL, fetcher, concurrency, defaultChannelSize)
+}
+
+// newAsyncHTTPParser is NewAsyncHTTPParser with
+// a queue holding up to queueSize messages
+func newAsyncHTTPParser(seedURL *url.URL, fetcher Fetcher, concurrency, queueSize int) *AsyncHTTPParser {
+	resQueue := make(parserResponseQueue, queueSize)
 	a := &AsyncHTTPParser{
 		AsyncWorker: NewAsyncWorker("Parser"),
 
 		fetcher:             fetcher,
 		parserResponseQueue: &resQueue,
 		seed:                seedURL,
+		canonicalizer:       util.NewCanonicalizer(),
+		concurrency:         concurrency,
+		logger:              stdLogger{},
+	}
+	if seedURL != nil {
+		a.scope = DefaultScope(seedURL)
 	}
 	a.AsyncWorker.RunFunc = a.Run
 	return a
 }
 
-// Run starts a loop that waits for requests
-// or the quit signal. Run will be interrupted
-// once the Stop method is used
-func (p *AsyncHTTPParser) Run() error {
-	p.AsyncWorker.SetState(RUNNING)
+// Run starts a pool of goroutines that wait for
+// responses from the Fetcher. Run blocks until
+// ctx is done
+func (p *AsyncHTTPParser) Run(ctx context.Context) error {
+	p.AsyncWorker.SetState(WAITING)
+	return p.AsyncWorker.RunPool(ctx, p.concurrency, p.parse)
+}
+
+// parse is the loop run by every goroutine of
+// the pool. It returns once ctx is done
+func (p *AsyncHTTPParser) parse(ctx context.Context) {
 	for {
-		p.AsyncWorker.SetState(WAITING)
 		select {
 		case res := <-*p.fetcher.ResponseChannel():
-			if err := p.handleResponse(res); err != nil {
-				if p.seed.String() == res.Request.String() {
-					return err
-				}
-				continue
+			p.AsyncWorker.markBusy()
+			p.handleResponse(ctx, res)
+			p.AsyncWorker.markIdle()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleResponse passes every link found in res to the Tracker,
+// followed by a message marking the page as done, even when
+// the page could not be fetched. The Tracker counts on the
+// latter to detect when the crawl is over. The PageResult of
+// the page is sent before the page is marked as done
+func (p *AsyncHTTPParser) handleResponse(ctx context.Context, res *FetchMessage) {
+	if res.Error == nil && p.inScope(res.URL()) {
+		if err := p.middlewares.response(res.Response); err != nil {
+			res.Error = err
+		}
+	}
+	req := res.Request
+	result := newPageResult(res)
+	if redirects := res.redirects(); len(redirects) > 0 {
+		m := &ParseMessage{
+			Request:   req.URL,
+			Depth:     req.Depth,
+			Response:  res.URL(),
+			Redirects: redirects,
+		}
+		if !p.send(ctx, m) {
+			return
+		}
+	}
+
+	switch {
+	case res.Error != nil:
+		p.logger.Printf("Could not get %s: %v", req.URL.String(), res.Error)
+	case !p.inScope(res.URL()):
+		util.Printf("Parser: Not parsing %v, redirected out of scope\n", res.URL())
+	case !IsHTML(result.MediaType):
+		util.Printf("Parser: Not parsing %v, of type %q\n", res.URL(), result.MediaType)
+		result.Bytes = int64(len(res.Response.Body))
+		result.Directives = headerDirectives(res.Response.Header)
+	default:
+		result.Bytes = int64(len(res.Response.Body))
+		links, info := p.extractLinks(res)
+		result.Links = links
+		result.Directives = addDirectives(headerDirectives(res.Response.Header), info.directives...)
+		result.Canonical = info.canonical
+		result.Title, result.Description = info.title, info.description
+		result.H1, result.H2 = info.h1, info.h2
+		result.Language = info.language
+		result.Charset = info.charset
+		nofollow := sitemap.HasDirective(result.Directives, sitemap.Nofollow)
+		for i, link := range result.Links {
+			if nofollow {
+				result.Links[i].Nofollow = true
+			}
+			util.Printf("Parser: Passing %s url %v to Tracker", link.Kind, link.URL)
+			m := &ParseMessage{
+				Request:  req.URL,
+				Depth:    req.Depth,
+				Response: link.URL,
+				Kind:     link.Kind,
+				Nofollow: result.Links[i].Nofollow,
+			}
+			if !p.send(ctx, m) {
+				return
 			}
-		case <-p.AsyncWorker.Quit:
-			p.Worker().SetState(STOPPED)
-			return nil
 		}
 	}
+
+	p.middlewares.page(*result)
+	if !p.sendResult(ctx, result) {
+		return
+	}
+	p.send(ctx, &ParseMessage{
+		Request:    req.URL,
+		Depth:      req.Depth,
+		Done:       true,
+		Bytes:      result.Bytes,
+		MediaType:  result.MediaType,
+		Page:       result.page(),
+		Directives: result.Directives,
+		Canonical:  result.Canonical,
+		Error:      res.Error,
+	})
+}
+
+// send places m on the Parser's ResponseChannel. It returns
+// false if the Parser was stopped before m could be sent
+func (p *AsyncHTTPParser) send(ctx context.Context, m *ParseMessage) bool {
+	select {
+	case *p.parserResponseQueue <- m:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
-func (p *AsyncHTTPParser) handleResponse(res *FetchMessage) error {
-	if res.Error != nil {
-		if res.Request.String() == p.seed.String() {
-			p.Stop()
+// sendResult places r on the results channel, if any. It returns
+// false if the Parser was stopped before r could be sent
+func (p *AsyncHTTPParser) sendResult(ctx context.Context, r *PageResult) bool {
+	if p.results == nil {
+		return true
+	}
+	select {
+	case p.results <- *r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// pageInfo is what is found in a page besides its links
+type pageInfo struct {
+	// directives are those of the robots meta tags
+	directives []sitemap.Directive
+
+	// canonical is the URL of the first <link rel="canonical">
+	canonical *url.URL
+
+	// title, description, h1 and h2 are the text of the first
+	// <title>, of the meta description and of the <h1> and <h2>,
+	// and language the lang of the <html>
+	title       string
+	description string
+	h1          []string
+	h2          []string
+	language    string
+
+	// charset is the charset the page was decoded from
+	charset string
+}
+
+// addText records text, the content of
+// a <title>, <h1> or <h2>, in the pageInfo
+func (info *pageInfo) addText(tag, text string) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return
+	}
+	switch tag {
+	case "title":
+		if info.title == "" {
+			info.title = text
 		}
-		log.Printf("Could not get %s: %v", res.Request.String(), res.Error)
-		return res.Error
+	case "h1":
+		info.h1 = append(info.h1, text)
+	case "h2":
+		info.h2 = append(info.h2, text)
 	}
-	p.AsyncWorker.SetState(RUNNING)
-	p.extractLinks(res)
-	return nil
 }
 
-func (p *AsyncHTTPParser) extractLinks(res *FetchMessage) error {
-	z := html.NewTokenizer(res.Response.Body)
+// extractLinks returns the links of the page in res that
+// are in the Parser's Scope and whose kind is not ignored
+// by its LinkPolicy, along with the pageInfo of the page.
+// The page is transcoded to UTF-8 before it is tokenized.
+// Relative links are resolved against the URL of the page
+// or, if the page has one, against its <base href>
+func (p *AsyncHTTPParser) extractLinks(res *FetchMessage) ([]Link, pageInfo) {
+	links := make([]Link, 0)
+	var info pageInfo
+	base := res.URL()
+
+	// text holds the text of the element
+	// being read, if textTag is set
+	var text bytes.Buffer
+	var textTag string
+
+	hasBase := false
+	var body []byte
+	body, info.charset = decodeBody(res.Response.Body, res.Response.Header.Get("Content-Type"))
+	z := html.NewTokenizer(bytes.NewReader(body))
 	done := false
 	for {
 		if done {
@@ -99,44 +305,158 @@ func (p *AsyncHTTPParser) extractLinks(res *FetchMessage) error {
 			// End of the document, we're done
 			done = true
 			break
-		case tt == html.StartTagToken:
+		case tt == html.TextToken:
+			if textTag != "" {
+				text.Write(z.Text())
+			}
+		case tt == html.EndTagToken:
+			if name, _ := z.TagName(); textTag != "" && string(name) == textTag {
+				info.addText(textTag, text.String())
+				textTag = ""
+			}
+		case tt == html.StartTagToken || tt == html.SelfClosingTagToken:
 			t := z.Token()
 
-			// Check if the token is an <a> tag
-			isAnchor := t.Data == "a"
-			if !isAnchor {
-				continue
+			switch {
+			case t.Data == "html" && info.language == "":
+				info.language = strings.TrimSpace(getAttr(t, "lang"))
+			case (t.Data == "title" || t.Data == "h1" || t.Data == "h2") &&
+				textTag == "" && tt == html.StartTagToken:
+				textTag = t.Data
+				text.Reset()
+			case t.Data == "meta" && strings.EqualFold(getAttr(t, "name"), "description"):
+				if info.description == "" {
+					info.description = strings.Join(strings.Fields(getAttr(t, "content")), " ")
+				}
 			}
 
-			// Extract the href value, if there is one
-			ok, url := getHref(t)
-			if !ok {
+			// Only the first <base> of a document counts
+			if t.Data == "base" && !hasBase {
+				if ok, href := getHref(t); ok {
+					if u, err := resolveLink(base, href); err == nil {
+						base, hasBase = u, true
+					}
+				}
 				continue
 			}
 
-			// Make sure the url begines in http**
-			isRelative := strings.HasPrefix(url, "/")
-			if isRelative {
-				url = fmt.Sprintf("%s://%s%s", p.seed.Scheme, p.seed.Host, url)
-			}
-			normURL, err := util.NormalizeStringURL(url)
-			if err != nil {
-				util.Printf("Parser: Error while normalizing %v: %v", url, err)
+			if t.Data == "meta" && strings.EqualFold(getAttr(t, "name"), "robots") {
+				info.directives = addDirectives(info.directives, parseDirectives(getAttr(t, "content"))...)
 				continue
 			}
-			hasProto := strings.Index(normURL.Scheme, "http") == 0
-			inSeedDomain := strings.Index(normURL.String(), p.seed.String()) == 0
-			if hasProto && inSeedDomain {
-				util.Printf("Parser: Passing url %v to Tracker", normURL)
-				*p.parserResponseQueue <- &ParseMessage{
-					Request:  res.Request,
-					Response: normURL,
+
+			if t.Data == "link" && info.canonical == nil && hasToken(getAttr(t, "rel"), "canonical") {
+				info.canonical = p.resolveCanonical(base, getAttr(t, "href"))
+			}
+
+			for _, raw := range tokenLinks(t) {
+				if p.links.action(raw.kind) == IgnoreLink {
+					continue
+				}
+				if u := p.resolve(res.URL(), base, raw.href); u != nil {
+					links = append(links, Link{URL: u, Kind: raw.kind, Nofollow: raw.nofollow})
 				}
 			}
 		}
 	}
 
-	return nil
+	return links, info
+}
+
+// resolveCanonical returns the canonical URL href, resolved
+// against base and canonicalized, or nil if it is not a URL
+// that can be crawled. Unlike links, canonical URLs out of
+// the Parser's Scope are kept, for them to be reported
+func (p *AsyncHTTPParser) resolveCanonical(base *url.URL, href string) *url.URL {
+	if strings.TrimSpace(href) == "" {
+		return nil
+	}
+	link, err := resolveLink(base, href)
+	if err != nil {
+		return nil
+	}
+	canonical, err := p.canonicalizer.Canonicalize(link)
+	if err != nil || strings.Index(canonical.Scheme, "http") != 0 {
+		return nil
+	}
+	return canonical
+}
+
+// resolve returns the link to href found in page, resolved
+// against base and canonicalized, or nil if it is not to be
+// followed
+func (p *AsyncHTTPParser) resolve(page, base *url.URL, href string) *url.URL {
+	link, err := resolveLink(base, href)
+	if err != nil {
+		util.Printf("Parser: Error while resolving %v: %v", href, err)
+		return nil
+	}
+	if link = p.middlewares.link(page, link); link == nil {
+		return nil
+	}
+	normURL, err := p.canonicalizer.Canonicalize(link)
+	if err != nil {
+		util.Printf("Parser: Error while canonicalizing %v: %v", link, err)
+		return nil
+	}
+	hasProto := strings.Index(normURL.Scheme, "http") == 0
+	if !hasProto || !p.inScope(normURL) {
+		return nil
+	}
+	return normURL
+}
+
+// resolveLink resolves href, as found in a page,
+// against the base URL of the page (RFC 3986)
+func resolveLink(base *url.URL, href string) (*url.URL, error) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil, err
+	}
+	return base.ResolveReference(ref), nil
+}
+
+// inScope returns whether u is in the Parser's
+// Scope. Without a Scope, every URL is
+func (p *AsyncHTTPParser) inScope(u *url.URL) bool {
+	return p.scope == nil || p.scope.InScope(u)
+}
+
+// SetScope sets the Scope of the
+// links passed on to the Tracker
+func (p *AsyncHTTPParser) SetScope(s Scope) {
+	p.scope = s
+}
+
+// SetLinkPolicy sets which kinds of links are passed on to
+// the Tracker. Without one, the DefaultLinkPolicy applies
+func (p *AsyncHTTPParser) SetLinkPolicy(l LinkPolicy) {
+	p.links = l
+}
+
+// SetCanonicalizer sets the rules the links passed
+// on to the Tracker are canonicalized with
+func (p *AsyncHTTPParser) SetCanonicalizer(c *util.Canonicalizer) {
+	p.canonicalizer = c
+}
+
+// SetLogger sets the Logger the pages
+// that could not be fetched are reported to
+func (p *AsyncHTTPParser) SetLogger(l Logger) {
+	p.logger = l
+}
+
+// Use adds m to the Middleware whose OnResponse, OnLink
+// and OnPage hooks are called for every page parsed
+func (p *AsyncHTTPParser) Use(m ...Middleware) {
+	p.middlewares = append(p.middlewares, m...)
+}
+
+// SetResults makes the Parser send the PageResult of every
+// page it parses to results, which needs to be read from
+// for the Parser to carry on
+func (p *AsyncHTTPParser) SetResults(results chan<- PageResult) {
+	p.results = results
 }
 
 func (p *AsyncHTTPParser) ResponseChannel() *parserResponseQueue {
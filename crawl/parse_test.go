@@ -1,9 +1,13 @@
 package crawl
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/antoniou/go-crawler/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -40,7 +44,7 @@ type ParseTestSuite struct {
 	seedURL *url.URL
 }
 
-func NewTestParser(seedURL *url.URL, fetcher Fetcher) *AsyncHTTPParser {
+func NewTestParser(seedURL *url.URL, fetcher Fetcher) (*AsyncHTTPParser, func()) {
 	resQueue := make(parserResponseQueue)
 	a := &AsyncHTTPParser{
 		AsyncWorker: NewAsyncWorker("Parser"),
@@ -48,10 +52,12 @@ func NewTestParser(seedURL *url.URL, fetcher Fetcher) *AsyncHTTPParser {
 		fetcher:             fetcher,
 		parserResponseQueue: &resQueue,
 		seed:                seedURL,
+		scope:               DefaultScope(seedURL),
+		canonicalizer:       util.NewCanonicalizer(),
+		logger:              stdLogger{},
 	}
 	a.AsyncWorker.RunFunc = a.Run
-	go a.Worker().Run()
-	return a
+	return a, runWorker(a.Worker())
 }
 
 func NewMockFetcher() Fetcher {
@@ -68,23 +74,232 @@ func (suite *ParseTestSuite) SetupTest() {
 
 func (suite *ParseTestSuite) TestFetchValidAndInvalidResponse() {
 	f := NewMockFetcher()
-	p := NewTestParser(suite.seedURL, f)
+	p, stop := NewTestParser(suite.seedURL, f)
+	defer stop()
 	f.Fetch(p.seed)
 
 }
 
+func (suite *ParseTestSuite) TestParallelParsing() {
+	f := NewMockFetcher()
+	p := NewAsyncHTTPParser(suite.seedURL, f, 2)
+
+	// Parsing a page signals that it started
+	// and blocks until release is closed
+	reading := make(chan struct{}, 2)
+	release := make(chan struct{})
+	p.Use(Middleware{
+		OnResponse: func(res *Response) error {
+			reading <- struct{}{}
+			<-release
+			return nil
+		},
+	})
+	stop := runWorker(p.Worker())
+
+	for _, page := range []string{"/a", "/b"} {
+		req, _ := url.ParseRequestURI(suite.seedURL.String() + page)
+		*f.ResponseChannel() <- &FetchMessage{
+			Request: NewRequest(req),
+			Response: &Response{
+				URL:  req,
+				Body: []byte(`<a href="` + page + `/child">child</a>`),
+			},
+		}
+	}
+
+	// Both pages are being parsed at the same time
+	for i := 0; i < 2; i++ {
+		select {
+		case <-reading:
+		case <-time.After(time.Second):
+			suite.T().Fatalf("only %d pages parsed in parallel", i)
+		}
+	}
+
+	close(release)
+	found := make([]string, 0)
+	for pages := 0; pages < 2; {
+		m := <-*p.ResponseChannel()
+		if m.Done {
+			pages++
+			continue
+		}
+		found = append(found, m.Response.String())
+	}
+	assert.Len(suite.T(), found, 2)
+	assert.Contains(suite.T(), found, "http://example.com/a/child")
+	assert.Contains(suite.T(), found, "http://example.com/b/child")
+	stop()
+}
+
+func (suite *ParseTestSuite) TestDoneAfterFailedFetch() {
+	f := NewMockFetcher()
+	p, stop := NewTestParser(suite.seedURL, f)
+
+	*f.ResponseChannel() <- &FetchMessage{
+		Request: NewRequest(suite.seedURL),
+		Error:   fmt.Errorf("no such host"),
+	}
+	m := <-*p.ResponseChannel()
+	assert.True(suite.T(), m.Done)
+	assert.Equal(suite.T(), suite.seedURL, m.Request)
+
+	// The Parser keeps running after a failed seed
+	assert.NotEqual(suite.T(), STOPPED, p.Worker().State())
+	stop()
+}
+
+func (suite *ParseTestSuite) TestDepthAndSizeArePassedOn() {
+	f := NewMockFetcher()
+	p, stop := NewTestParser(suite.seedURL, f)
+	defer stop()
+
+	body := `<a href="/about">About</a>`
+	*f.ResponseChannel() <- &FetchMessage{
+		Request: &Request{URL: suite.seedURL, Depth: 2},
+		Response: &Response{
+			StatusCode: http.StatusOK,
+			Body:       []byte(body),
+		},
+	}
+
+	m := <-*p.ResponseChannel()
+	assert.Equal(suite.T(), "http://example.com/about", m.Response.String())
+	assert.Equal(suite.T(), 2, m.Depth)
+	m = <-*p.ResponseChannel()
+	assert.True(suite.T(), m.Done)
+	assert.Equal(suite.T(), 2, m.Depth)
+	assert.Equal(suite.T(), int64(len(body)), m.Bytes)
+}
+
+func (suite *ParseTestSuite) TestResolveLinkRFC3986Examples() {
+	// RFC 3986, section 5.4
+	base, _ := url.Parse("http://a/b/c/d;p?q")
+	examples := map[string]string{
+		// Normal examples
+		"g:h":     "g:h",
+		"g":       "http://a/b/c/g",
+		"./g":     "http://a/b/c/g",
+		"g/":      "http://a/b/c/g/",
+		"/g":      "http://a/g",
+		"//g":     "http://g",
+		"?y":      "http://a/b/c/d;p?y",
+		"g?y":     "http://a/b/c/g?y",
+		"#s":      "http://a/b/c/d;p?q#s",
+		"g#s":     "http://a/b/c/g#s",
+		"g?y#s":   "http://a/b/c/g?y#s",
+		";x":      "http://a/b/c/;x",
+		"g;x":     "http://a/b/c/g;x",
+		"g;x?y#s": "http://a/b/c/g;x?y#s",
+		"":        "http://a/b/c/d;p?q",
+		".":       "http://a/b/c/",
+		"./":      "http://a/b/c/",
+		"..":      "http://a/b/",
+		"../":     "http://a/b/",
+		"../g":    "http://a/b/g",
+		"../..":   "http://a/",
+		"../../":  "http://a/",
+		"../../g": "http://a/g",
+
+		// Abnormal examples
+		"../../../g":    "http://a/g",
+		"../../../../g": "http://a/g",
+		"/./g":          "http://a/g",
+		"/../g":         "http://a/g",
+		"g.":            "http://a/b/c/g.",
+		".g":            "http://a/b/c/.g",
+		"g..":           "http://a/b/c/g..",
+		"..g":           "http://a/b/c/..g",
+		"./../g":        "http://a/b/g",
+		"./g/.":         "http://a/b/c/g/",
+		"g/./h":         "http://a/b/c/g/h",
+		"g/../h":        "http://a/b/c/h",
+		"g;x=1/./y":     "http://a/b/c/g;x=1/y",
+		"g;x=1/../y":    "http://a/b/c/y",
+		"g?y/./x":       "http://a/b/c/g?y/./x",
+		"g?y/../x":      "http://a/b/c/g?y/../x",
+		"g#s/./x":       "http://a/b/c/g#s/./x",
+		"g#s/../x":      "http://a/b/c/g#s/../x",
+	}
+	for ref, expected := range examples {
+		u, err := resolveLink(base, ref)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), expected, u.String(), "resolving %q", ref)
+	}
+}
+
+func (suite *ParseTestSuite) TestLinksResolvedAgainstPageURL() {
+	p := NewAsyncHTTPParser(suite.seedURL, NewMockFetcher(), 1)
+	page, _ := url.ParseRequestURI("http://example.com/docs/guide/")
+	links, _ := p.extractLinks(&FetchMessage{
+		Request: NewRequest(page),
+		Response: &Response{Body: []byte(`
+			<a href="about.html">About</a>
+			<a href="../api/">API</a>
+			<a href="/news">News</a>
+			<a href=" ./intro ">Intro</a>
+			<a href="//cdn.example.com/x">CDN</a>
+			<a href="//example.com/y">Same host</a>
+			<a href="mailto:me@example.com">Mail</a>`)},
+	})
+
+	assert.Equal(suite.T(), []string{
+		"http://example.com/docs/guide/about.html",
+		"http://example.com/docs/api/",
+		"http://example.com/news",
+		"http://example.com/docs/guide/intro",
+		"http://example.com/y",
+	}, linkStrings(links))
+}
+
+func (suite *ParseTestSuite) TestLinksResolvedAgainstRedirectedURL() {
+	p := NewAsyncHTTPParser(suite.seedURL, NewMockFetcher(), 1)
+	from, _ := url.ParseRequestURI("http://example.com/old")
+	to, _ := url.ParseRequestURI("http://example.com/new/")
+	links, _ := p.extractLinks(&FetchMessage{
+		Request: NewRequest(from),
+		Response: &Response{
+			URL:       to,
+			Redirects: []Redirect{{From: from, To: to, Status: http.StatusFound}},
+			Body:      []byte(`<a href="page">Page</a>`),
+		},
+	})
+	assert.Equal(suite.T(), []string{"http://example.com/new/page"}, linkStrings(links))
+}
+
+func (suite *ParseTestSuite) TestLinksResolvedAgainstBaseHref() {
+	p := NewAsyncHTTPParser(suite.seedURL, NewMockFetcher(), 1)
+	page, _ := url.ParseRequestURI("http://example.com/docs/guide/")
+	links, _ := p.extractLinks(&FetchMessage{
+		Request: NewRequest(page),
+		Response: &Response{Body: []byte(`
+			<html><head>
+			<base href="/static/v2/" />
+			<base href="/ignored/">
+			</head><body>
+			<a href="about.html">About</a>
+			<a href="../v1/">Previous</a>
+			</body></html>`)},
+	})
+	assert.Equal(suite.T(), []string{
+		"http://example.com/static/v2/about.html",
+		"http://example.com/static/v1/",
+	}, linkStrings(links))
+}
+
 func (suite *ParseTestSuite) TestStopParser() {
 	f := NewMockFetcher()
-	p := NewTestParser(suite.seedURL, f)
+	p, stop := NewTestParser(suite.seedURL, f)
 	assert.Equal(suite.T(), WAITING, p.Worker().State())
 
-	p.Stop()
+	stop()
 	assert.Equal(suite.T(), STOPPED, p.Worker().State())
 }
 
 func (suite *ParseTestSuite) TestNewAsyncHTTPParserConstructor() {
 	f := NewMockFetcher()
-	p := NewAsyncHTTPParser(suite.seedURL, f)
+	p := NewAsyncHTTPParser(suite.seedURL, f, DefaultConcurrency)
 	assert.Implements(suite.T(), (*Parser)(nil), p)
 	assert.NotNil(suite.T(), p)
 }
@@ -92,3 +307,11 @@ func (suite *ParseTestSuite) TestNewAsyncHTTPParserConstructor() {
 func TestParseTestSuite(t *testing.T) {
 	suite.Run(t, new(ParseTestSuite))
 }
+
+func linkStrings(links []Link) []string {
+	s := make([]string, 0, len(links))
+	for _, l := range links {
+		s = append(s, l.URL.String())
+	}
+	return s
+}
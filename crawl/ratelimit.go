@@ -0,0 +1,138 @@
+package crawl
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HostLimiter paces the requests sent to each host. It
+// enforces a minimum delay between the start of two requests
+// to the same host, and a maximum number of connections
+// open to the same host at any time.
+// A nil *HostLimiter does not limit requests
+type HostLimiter struct {
+	delay    time.Duration
+	maxConns int
+
+	mutex sync.Mutex
+	hosts map[string]*hostLimit
+}
+
+// hostLimit holds the pacing state of a single host
+type hostLimit struct {
+	// next is the earliest time the next
+	// request to the host may start
+	next time.Time
+
+	// delay overrides the HostLimiter's delay
+	// when it is longer
+	delay time.Duration
+
+	// conns is a semaphore holding a token per
+	// open connection. It is nil when unlimited
+	conns chan struct{}
+}
+
+// NewHostLimiter is a HostLimiter constructor. delay is the
+// minimum time between two requests to the same host and
+// maxConns the maximum number of concurrent connections
+// per host. Zero values disable the respective limit
+func NewHostLimiter(delay time.Duration, maxConns int) *HostLimiter {
+	return &HostLimiter{
+		delay:    delay,
+		maxConns: maxConns,
+		hosts:    make(map[string]*hostLimit),
+	}
+}
+
+// RequestsPerSecond returns the delay between requests
+// to a host that results in rps requests per second
+func RequestsPerSecond(rps float64) time.Duration {
+	if rps <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rps)
+}
+
+// Acquire blocks until a request to host may start. It
+// returns a function that needs to be called once the
+// request is done, to release the connection to host
+func (l *HostLimiter) Acquire(host string) (release func()) {
+	release, _ = l.AcquireContext(context.Background(), host)
+	return release
+}
+
+// AcquireContext is Acquire, giving up with the error
+// of ctx if ctx is done before the request may start
+func (l *HostLimiter) AcquireContext(ctx context.Context, host string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	h := l.host(host)
+	if h.conns != nil {
+		select {
+		case h.conns <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if h.conns != nil {
+			<-h.conns
+		}
+	}
+
+	// Reserve the next free slot for host
+	l.mutex.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	delay := l.delay
+	if h.delay > delay {
+		delay = h.delay
+	}
+	h.next = start.Add(delay)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// SetDelay sets the minimum delay between two requests to
+// host, when it is longer than the HostLimiter's delay.
+// This is used to honour a host's robots.txt Crawl-delay
+func (l *HostLimiter) SetDelay(host string, delay time.Duration) {
+	if l == nil {
+		return
+	}
+
+	h := l.host(host)
+	l.mutex.Lock()
+	h.delay = delay
+	l.mutex.Unlock()
+}
+
+func (l *HostLimiter) host(host string) *hostLimit {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimit{}
+		if l.maxConns > 0 {
+			h.conns = make(chan struct{}, l.maxConns)
+		}
+		l.hosts[host] = h
+	}
+	return h
+}
@@ -0,0 +1,69 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RateLimitTestSuite) TestDelayBetweenRequestsToSameHost() {
+	l := NewHostLimiter(50*time.Millisecond, 0)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.Acquire("example.com")()
+	}
+	assert.True(suite.T(), time.Since(start) >= 100*time.Millisecond)
+
+	// Other hosts are paced independently
+	start = time.Now()
+	l.Acquire("other.com")()
+	assert.True(suite.T(), time.Since(start) < 50*time.Millisecond)
+}
+
+func (suite *RateLimitTestSuite) TestMaxConnectionsPerHost() {
+	l := NewHostLimiter(0, 1)
+	release := l.Acquire("example.com")
+
+	acquired := make(chan struct{})
+	go func() {
+		l.Acquire("example.com")()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		suite.T().Fatal("second connection opened before the first was released")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		suite.T().Fatal("second connection not opened after release")
+	}
+}
+
+func (suite *RateLimitTestSuite) TestRequestsPerSecond() {
+	assert.Equal(suite.T(), 250*time.Millisecond, RequestsPerSecond(4))
+	assert.Equal(suite.T(), time.Duration(0), RequestsPerSecond(0))
+}
+
+func (suite *RateLimitTestSuite) TestNilLimiterDoesNotLimit() {
+	var l *HostLimiter
+	start := time.Now()
+	l.Acquire("example.com")()
+	l.Acquire("example.com")()
+	assert.True(suite.T(), time.Since(start) < 50*time.Millisecond)
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// maxRedirects is the number of redirects the
+// Fetcher follows for a single request
+const maxRedirects = 10
+
+// Redirect is a single hop of a chain of redirects
+type Redirect struct {
+	From   *url.URL
+	To     *url.URL
+	Status int
+}
+
+func (r Redirect) String() string {
+	return fmt.Sprintf("%s -[%d]-> %s", r.From, r.Status, r.To)
+}
+
+// checkRedirect is the CheckRedirect hook of the Fetcher's
+// http.Client. It stops following redirects once they loop
+// or after maxRedirects, in which case the client returns
+// the last redirect response instead of an error
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return http.ErrUseLastResponse
+	}
+	for _, r := range via {
+		if r.URL.String() == req.URL.String() {
+			return http.ErrUseLastResponse
+		}
+	}
+	return nil
+}
+
+// redirectChain returns the redirects that led to res, oldest
+// first, including the redirect res itself asks for when it
+// was not followed
+func redirectChain(res *http.Response) []Redirect {
+	if res == nil || res.Request == nil {
+		return nil
+	}
+
+	hops := make([]Redirect, 0)
+	for req := res.Request; req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		hops = append([]Redirect{{
+			From:   req.Response.Request.URL,
+			To:     req.URL,
+			Status: req.Response.StatusCode,
+		}}, hops...)
+	}
+
+	// A redirect response is only returned when
+	// checkRedirect has refused to follow it
+	location, err := res.Location()
+	if !isRedirect(res.StatusCode) || err != nil {
+		return hops
+	}
+	return append(hops, Redirect{
+		From:   res.Request.URL,
+		To:     location,
+		Status: res.StatusCode,
+	})
+}
+
+// redirectError returns an error if res is a redirect that
+// was not followed, because the redirects that led to it
+// loop or because there were too many of them
+func redirectError(res *Response) error {
+	n := len(res.Redirects)
+	if n == 0 || !isRedirect(res.StatusCode) {
+		return nil
+	}
+	if isRedirectLoop(res.Redirects) {
+		return fmt.Errorf("redirect loop back to %s", res.Redirects[n-1].To)
+	}
+	return fmt.Errorf("stopped after %d redirects", n-1)
+}
+
+// isRedirectLoop returns whether a chain of
+// redirects leads back to one of its URLs
+func isRedirectLoop(chain []Redirect) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	last := chain[len(chain)-1].To.String()
+	for _, hop := range chain {
+		if hop.From.String() == last {
+			return true
+		}
+	}
+	return false
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
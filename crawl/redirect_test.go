@@ -0,0 +1,150 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/antoniou/go-crawler/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RedirectTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (suite *RedirectTestSuite) SetupTest() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/old">Old</a><a href="/new">New</a><a href="/loop1">Loop</a>`)
+	})
+	mux.Handle("/old", http.RedirectHandler("/older", http.StatusMovedPermanently))
+	mux.Handle("/older", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/page">Page</a>`)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/loop1", http.RedirectHandler("/loop2", http.StatusFound))
+	mux.Handle("/loop2", http.RedirectHandler("/loop1", http.StatusFound))
+	mux.Handle("/away", http.RedirectHandler("http://elsewhere.invalid/", http.StatusFound))
+	suite.server = httptest.NewServer(mux)
+}
+
+func (suite *RedirectTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *RedirectTestSuite) fetch(path string) *FetchMessage {
+	f := NewAsyncHTTPFetcher(1)
+	defer runWorker(f.Worker())()
+
+	uri, _ := url.ParseRequestURI(suite.server.URL + path)
+	f.Fetch(uri)
+	return <-*f.ResponseChannel()
+}
+
+func (suite *RedirectTestSuite) TestRedirectChainIsRecorded() {
+	m := suite.fetch("/old")
+	assert.NoError(suite.T(), m.Error)
+	assert.Len(suite.T(), m.Response.Redirects, 2)
+	assert.Equal(suite.T(), suite.server.URL+"/old", m.Response.Redirects[0].From.String())
+	assert.Equal(suite.T(), suite.server.URL+"/older", m.Response.Redirects[0].To.String())
+	assert.Equal(suite.T(), http.StatusMovedPermanently, m.Response.Redirects[0].Status)
+	assert.Equal(suite.T(), suite.server.URL+"/new", m.URL().String())
+	assert.Equal(suite.T(), http.StatusFound, m.Response.Redirects[1].Status)
+
+	m = suite.fetch("/new")
+	assert.Empty(suite.T(), m.Response.Redirects)
+	assert.Equal(suite.T(), m.Request.URL, m.URL())
+}
+
+func (suite *RedirectTestSuite) TestRedirectLoopIsStopped() {
+	m := suite.fetch("/loop1")
+	assert.Error(suite.T(), m.Error)
+	assert.Contains(suite.T(), m.Error.Error(), "redirect loop")
+	assert.Len(suite.T(), m.Response.Redirects, 2)
+	assert.True(suite.T(), isRedirectLoop(m.Response.Redirects))
+}
+
+func (suite *RedirectTestSuite) TestRedirectsInSitemap() {
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	c := NewAsyncHTTPCrawler(2, 2)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	old, older, new := suite.server.URL+"/old", suite.server.URL+"/older", suite.server.URL+"/new"
+	assert.Equal(suite.T(), sitemap.LinkEdge, stmp.EdgeKind(suite.server.URL, old))
+	assert.Equal(suite.T(), sitemap.RedirectEdge, stmp.EdgeKind(old, older))
+	assert.Equal(suite.T(), sitemap.RedirectEdge, stmp.EdgeKind(older, new))
+
+	// /new is crawled once, whether reached directly or redirected to
+	assert.Equal(suite.T(), []string{suite.server.URL + "/page"}, *stmp.LinksFrom(new))
+	assert.Equal(suite.T(), 5, c.Report().Pages)
+
+	report := c.Report()
+	assert.Equal(suite.T(), 2, report.Redirects)
+	assert.Len(suite.T(), report.RedirectChains, 1)
+	assert.Len(suite.T(), report.RedirectLoops, 1)
+	assert.Contains(suite.T(), report.String(), "Redirect loops:")
+}
+
+func (suite *RedirectTestSuite) TestRedirectOutOfSeedDomainIsNotParsed() {
+	seedURL, _ := url.ParseRequestURI(suite.server.URL)
+	f := NewMockFetcher()
+	p, stop := NewTestParser(seedURL, f)
+	defer stop()
+
+	from, _ := url.ParseRequestURI(suite.server.URL + "/away")
+	to, _ := url.ParseRequestURI("http://elsewhere.invalid/")
+	*f.ResponseChannel() <- &FetchMessage{
+		Request: &Request{URL: from},
+		Response: &Response{
+			URL:        to,
+			Redirects:  []Redirect{{From: from, To: to, Status: http.StatusFound}},
+			StatusCode: http.StatusOK,
+		},
+	}
+
+	m := <-*p.ResponseChannel()
+	assert.Equal(suite.T(), to, m.Response)
+	assert.Len(suite.T(), m.Redirects, 1)
+	m = <-*p.ResponseChannel()
+	assert.True(suite.T(), m.Done)
+}
+
+func (suite *RedirectTestSuite) TestRedirectToSameCanonicalURL() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/blog/">Blog</a>`)
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/blog/1">First</a>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// /blog/ is requested as /blog, which the
+	// server redirects back to /blog/
+	canon := util.NewCanonicalizer()
+	canon.RemoveTrailingSlash = true
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewAsyncHTTPCrawler(1, 1)
+	c.SetCanonicalizer(canon)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	blog := server.URL + "/blog"
+	assert.Equal(suite.T(), sitemap.LinkEdge, stmp.EdgeKind(server.URL, blog))
+	assert.Equal(suite.T(), []string{blog + "/1"}, *stmp.LinksFrom(blog))
+	assert.Equal(suite.T(), 3, c.Report().Pages)
+}
+
+func TestRedirectTestSuite(t *testing.T) {
+	suite.Run(t, new(RedirectTestSuite))
+}
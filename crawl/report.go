@@ -0,0 +1,128 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Report summarises a crawl
+type Report struct {
+	// Pages is the number of pages crawled
+	Pages int
+
+	// Links is the number of links tracked
+	Links int
+
+	// Bytes is the size of the pages crawled
+	Bytes int64
+
+	// Seen holds statistics on the URLs seen
+	Seen SeenStats
+
+	// Limit describes the limit that left pages
+	// out of the crawl, if one was reached
+	Limit string
+
+	// BeyondDepth is the number of links found beyond
+	// MaxDepth, which were not followed. They do not
+	// make the sitemap incomplete
+	BeyondDepth int
+
+	// Interrupted is set if the crawl was interrupted
+	Interrupted bool
+
+	// Failures holds the final cause of failure
+	// of every page that could not be fetched
+	Failures map[string]error
+
+	// Redirects is the number of requests that were redirected
+	Redirects int
+
+	// RedirectChains holds the chains of two
+	// redirects or more that were followed
+	RedirectChains [][]Redirect
+
+	// RedirectLoops holds the chains of
+	// redirects that looped
+	RedirectLoops [][]Redirect
+
+	// CanonicalIssues holds the pages whose canonical
+	// URL is off-site, not answered with a 200, or
+	// the start of a chain of canonicals
+	CanonicalIssues []CanonicalIssue
+}
+
+// addRedirects records a chain of redirects
+func (r *Report) addRedirects(chain []Redirect, loop bool) {
+	r.Redirects++
+	switch {
+	case loop:
+		r.RedirectLoops = append(r.RedirectLoops, chain)
+	case len(chain) > 1:
+		r.RedirectChains = append(r.RedirectChains, chain)
+	}
+}
+
+// NewReport is a Report constructor
+func NewReport() *Report {
+	return &Report{
+		Failures: make(map[string]error),
+	}
+}
+
+// String renders the Report for the command line
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Crawled %d pages (%d bytes), tracked %d links\n", r.Pages, r.Bytes, r.Links)
+	fmt.Fprintf(&buf, "%s\n", r.Seen)
+	switch {
+	case r.Interrupted:
+		fmt.Fprintf(&buf, "Interrupted, the sitemap is incomplete\n")
+	case r.Limit != "":
+		fmt.Fprintf(&buf, "Reached %s, the sitemap is incomplete\n", r.Limit)
+	}
+	if r.BeyondDepth > 0 {
+		fmt.Fprintf(&buf, "%d links beyond the max depth were not followed\n", r.BeyondDepth)
+	}
+
+	if r.Redirects > 0 {
+		fmt.Fprintf(&buf, "%d requests were redirected\n", r.Redirects)
+	}
+	writeRedirects(&buf, "Redirect chains", r.RedirectChains)
+	writeRedirects(&buf, "Redirect loops", r.RedirectLoops)
+
+	if len(r.CanonicalIssues) > 0 {
+		fmt.Fprintf(&buf, "Canonical issues:\n")
+		for _, issue := range r.CanonicalIssues {
+			fmt.Fprintf(&buf, "  %s\n", issue)
+		}
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(&buf, "%d pages could not be fetched:\n", len(r.Failures))
+		urls := make([]string, 0, len(r.Failures))
+		for u := range r.Failures {
+			urls = append(urls, u)
+		}
+		sort.Strings(urls)
+		for _, u := range urls {
+			fmt.Fprintf(&buf, "  %s: %v\n", u, r.Failures[u])
+		}
+	}
+	return buf.String()
+}
+
+func writeRedirects(buf *bytes.Buffer, title string, chains [][]Redirect) {
+	if len(chains) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "%s:\n", title)
+	for _, chain := range chains {
+		fmt.Fprintf(buf, "  %s", chain[0].From)
+		for _, hop := range chain {
+			fmt.Fprintf(buf, " -[%d]-> %s", hop.Status, hop.To)
+		}
+		fmt.Fprintln(buf)
+	}
+}
@@ -0,0 +1,120 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/antoniou/go-crawler/sitemap"
+)
+
+// PageResult is what the crawl found out about a page
+// requested at Request, Depth clicks away from the seed
+type PageResult struct {
+	Request *url.URL
+	Depth   int
+
+	// URL is the URL of the page,
+	// which Redirects led to from Request
+	URL       *url.URL
+	Redirects []Redirect
+
+	// Status and Header are those of the response,
+	// if the page could be requested at all
+	Status int
+	Header http.Header
+
+	// MediaType is the media type of the page. Only
+	// pages of the HTML family are parsed for links
+	MediaType string
+
+	// Charset is the charset an HTML page was
+	// transcoded to UTF-8 from before it was parsed
+	Charset string
+
+	// Directives are the robots directives of the page,
+	// as given by its robots meta tags and X-Robots-Tag
+	Directives []sitemap.Directive
+
+	// Canonical is the URL the page declares as its
+	// canonical one with <link rel="canonical">, if any
+	Canonical *url.URL
+
+	// Title, Description, H1 and H2 are the text of the
+	// <title>, the meta description and the <h1> and <h2>
+	// of the page, and Language the lang of its <html>
+	Title       string
+	Description string
+	H1          []string
+	H2          []string
+	Language    string
+
+	// Links are the links of the page in the Scope
+	// of the crawl, in the order found, but for those
+	// whose kind is ignored
+	Links []Link
+
+	// Bytes is the size of the body of the page, which
+	// was cut at the maximum body size if Truncated
+	Bytes     int64
+	Truncated bool
+
+	// ResponseTime is the time it took to get the page
+	ResponseTime time.Duration
+
+	// Error is set if the page could not be fetched
+	Error error
+}
+
+// newPageResult returns the PageResult of the
+// page in res, before it has been parsed
+func newPageResult(res *FetchMessage) *PageResult {
+	r := &PageResult{
+		Request:   res.Request.URL,
+		Depth:     res.Request.Depth,
+		URL:       res.URL(),
+		Redirects: res.redirects(),
+		Links:     make([]Link, 0),
+		Error:     res.Error,
+	}
+	if res.Response != nil {
+		r.Status = res.Response.StatusCode
+		r.Header = res.Response.Header
+		r.MediaType = responseMediaType(res.Response)
+		r.Truncated = res.Response.Truncated
+		r.ResponseTime = res.Response.Timings.Total
+	}
+	return r
+}
+
+// page returns the sitemap.Page of the page. Its length
+// is that declared by its Content-Length header, if any,
+// for the bodies that were cut or not downloaded, and its
+// language falls back on its Content-Language header
+func (r *PageResult) page() sitemap.Page {
+	p := sitemap.Page{
+		Status:        r.Status,
+		ContentType:   r.MediaType,
+		ContentLength: r.Bytes,
+		ResponseTime:  r.ResponseTime,
+		Title:         r.Title,
+		Description:   r.Description,
+		H1:            r.H1,
+		H2:            r.H2,
+		Language:      r.Language,
+	}
+	if n, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64); err == nil {
+		p.ContentLength = n
+	}
+	if p.Language == "" {
+		p.Language = r.Header.Get("Content-Language")
+	}
+	if t, err := http.ParseTime(r.Header.Get("Last-Modified")); err == nil {
+		p.LastModified = &t
+	}
+	if r.Error != nil {
+		p.Error = r.Error.Error()
+	}
+	return p
+}
@@ -0,0 +1,177 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResultTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *ResultTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+// collect returns a channel receiving the
+// PageResults of c, by URL, once it is closed
+func collect(c *AsyncHTTPCrawler) <-chan map[string]PageResult {
+	collected := make(chan map[string]PageResult, 1)
+	results := c.Results()
+	go func() {
+		pages := make(map[string]PageResult)
+		for r := range results {
+			pages[r.Request.String()] = r
+		}
+		collected <- pages
+	}()
+	return collected
+}
+
+func (suite *ResultTestSuite) TestResults() {
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 2, 2)
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	pages := <-collected
+	assert.Len(suite.T(), pages, 6)
+	news := pages["http://example.com/news"]
+	assert.Equal(suite.T(), http.StatusOK, news.Status)
+	assert.Equal(suite.T(), 1, news.Depth)
+	assert.Equal(suite.T(), []string{"http://example.com/news/1", "http://example.com/news/2"},
+		linkStrings(news.Links))
+	assert.Equal(suite.T(), int64(len(testSite["http://example.com/news"])), news.Bytes)
+	assert.Equal(suite.T(), 2, pages["http://example.com/news/1"].Depth)
+
+	missing := pages["http://example.com/missing"]
+	assert.Equal(suite.T(), http.StatusNotFound, missing.Status)
+	assert.Empty(suite.T(), missing.Links)
+
+	// The sitemap is built all the same
+	assert.Len(suite.T(), *stmp.LinksFrom("http://example.com/news"), 2)
+}
+
+func (suite *ResultTestSuite) TestFailedPageResult() {
+	c := NewTestCrawler(&mockHTTPClient{}, 1, 1)
+	collected := collect(c)
+	seedURL, _ := url.ParseRequestURI("http://nonexistingwebsite.com")
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	r := (<-collected)["http://nonexistingwebsite.com"]
+	assert.EqualError(suite.T(), r.Error, "no such host")
+	assert.Equal(suite.T(), 0, r.Status)
+	assert.Nil(suite.T(), r.Header)
+
+	page, ok := stmp.Page("http://nonexistingwebsite.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), sitemap.Page{Error: "no such host"}, page)
+}
+
+func (suite *ResultTestSuite) TestPages() {
+	modified := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		fmt.Fprint(w, `<html lang="en-GB"><head>
+			<title>
+				Home  page
+			</title>
+			<meta name="description" content="All about  us">
+			</head><body>
+			<h1>Welcome <span>home</span></h1>
+			<h2>News</h2><h2></h2><h2>Contact</h2>
+			<svg><title>Logo</title></svg>
+			<a href="/report.pdf">Report</a>
+			</body></html>`)
+	})
+	mux.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Language", "fr")
+		fmt.Fprint(w, strings.Repeat("%PDF", 250))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewAsyncHTTPCrawler(1, 1)
+	collected := collect(c)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	r := (<-collected)[server.URL]
+	assert.Equal(suite.T(), "Home page", r.Title)
+	assert.Equal(suite.T(), []string{"Welcome home"}, r.H1)
+
+	home, ok := stmp.Page(server.URL)
+	assert.True(suite.T(), ok)
+	assert.True(suite.T(), home.ResponseTime > 0)
+	home.ResponseTime = 0
+	assert.Equal(suite.T(), sitemap.Page{
+		Status:        http.StatusOK,
+		ContentType:   "text/html",
+		ContentLength: r.Bytes,
+		Title:         "Home page",
+		Description:   "All about us",
+		H1:            []string{"Welcome home"},
+		H2:            []string{"News", "Contact"},
+		Language:      "en-GB",
+		LastModified:  &modified,
+	}, home)
+
+	// The length of a resource not downloaded is the one declared
+	pdf, ok := stmp.Page(server.URL + "/report.pdf")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "application/pdf", pdf.ContentType)
+	assert.Equal(suite.T(), int64(1000), pdf.ContentLength)
+	assert.Equal(suite.T(), "fr", pdf.Language)
+	assert.Equal(suite.T(), "", pdf.Title)
+}
+
+func (suite *ResultTestSuite) TestRedirectedPageResult() {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.RedirectHandler("/home", http.StatusMovedPermanently))
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<a href="/home">Home</a>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewAsyncHTTPCrawler(1, 1)
+	collected := collect(c)
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	r := (<-collected)[server.URL]
+	assert.Equal(suite.T(), server.URL+"/home", r.URL.String())
+	assert.Len(suite.T(), r.Redirects, 1)
+	assert.Equal(suite.T(), "text/html; charset=utf-8", r.Header.Get("Content-Type"))
+}
+
+func (suite *ResultTestSuite) TestResultsClosedOnError() {
+	c := NewAsyncHTTPCrawler(1, 1)
+	results := c.Results()
+	_, err := c.Crawl(context.Background())
+	assert.Error(suite.T(), err)
+	_, open := <-results
+	assert.False(suite.T(), open)
+}
+
+func TestResultTestSuite(t *testing.T) {
+	suite.Run(t, new(ResultTestSuite))
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how the Fetcher retries requests
+// that fail with a transport error or with a 429 or 5xx
+// response. Delays between attempts grow exponentially from
+// BaseDelay up to MaxDelay, with a random jitter, unless the
+// response asks for a specific delay with Retry-After.
+// A nil *RetryPolicy never retries
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts
+	// per request, including the first one
+	Attempts int
+
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// NewRetryPolicy is a RetryPolicy constructor
+func NewRetryPolicy(attempts int, baseDelay, maxDelay time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		Attempts:  attempts,
+		BaseDelay: baseDelay,
+		MaxDelay:  maxDelay,
+	}
+}
+
+// Retryable returns whether a request that resulted
+// in res and err is worth another attempt
+func (p *RetryPolicy) Retryable(res *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests ||
+		res.StatusCode >= http.StatusInternalServerError
+}
+
+// Delay returns how long to wait before the attempt following
+// attempt (starting from 1), which resulted in res. It returns
+// false if there should be no further attempt
+func (p *RetryPolicy) Delay(attempt int, res *Response) (time.Duration, bool) {
+	if p == nil || attempt >= p.Attempts {
+		return 0, false
+	}
+
+	if res != nil {
+		if d, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			// Retrying earlier than asked for would be rude
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+
+	d := p.BaseDelay << uint(attempt-1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+
+	// Wait between half and all of the delay, so that
+	// failed requests are not all retried at once
+	half := int64(d / 2)
+	if half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d, true
+}
+
+// parseRetryAfter parses the value of a Retry-After header,
+// given either in seconds or as an HTTP-date, into a delay
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
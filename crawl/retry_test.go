@@ -0,0 +1,190 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// flakyHTTPClient fails the first failures requests to
+// every URL, with err when set or with status otherwise
+type flakyHTTPClient struct {
+	failures int
+	status   int
+	err      error
+	header   http.Header
+	attempts map[string]int
+}
+
+func (c *flakyHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	url := req.URL.String()
+	c.attempts[url]++
+	if c.attempts[url] > c.failures {
+		return &Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &Response{
+		Status:     fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)),
+		StatusCode: c.status,
+		Header:     c.header,
+	}, nil
+}
+
+type RetryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RetryTestSuite) fetch(client HTPPClient, policy *RetryPolicy) *FetchMessage {
+	f := NewAsyncHTTPFetcher(1)
+	f.client = client
+	f.SetRetryPolicy(policy)
+	defer runWorker(f.Worker())()
+
+	uri, _ := url.ParseRequestURI("http://example.com")
+	f.Fetch(uri)
+	return <-*f.ResponseChannel()
+}
+
+func (suite *RetryTestSuite) TestRetryUntilSuccess() {
+	client := &flakyHTTPClient{
+		failures: 2,
+		err:      fmt.Errorf("connection reset"),
+		attempts: make(map[string]int),
+	}
+	m := suite.fetch(client, NewRetryPolicy(3, time.Millisecond, 10*time.Millisecond))
+	assert.NoError(suite.T(), m.Error)
+	assert.Equal(suite.T(), http.StatusOK, m.Response.StatusCode)
+	assert.Equal(suite.T(), 3, client.attempts["http://example.com"])
+}
+
+func (suite *RetryTestSuite) TestCancelDuringRetry() {
+	client := &flakyHTTPClient{
+		failures: 5,
+		status:   http.StatusServiceUnavailable,
+		attempts: make(map[string]int),
+	}
+	f := NewAsyncHTTPFetcher(1)
+	f.client = client
+	f.SetRetryPolicy(NewRetryPolicy(3, time.Minute, time.Minute))
+
+	// A request cancelled while waiting to be retried
+	// fails with the error of its context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	uri, _ := url.ParseRequestURI("http://example.com")
+	_, err := f.get(ctx, NewRequest(uri))
+	assert.Equal(suite.T(), context.DeadlineExceeded, err)
+	assert.Equal(suite.T(), 1, client.attempts["http://example.com"])
+}
+
+func (suite *RetryTestSuite) TestPermanentFailureKeepsFinalCause() {
+	client := &flakyHTTPClient{
+		failures: 5,
+		status:   http.StatusServiceUnavailable,
+		attempts: make(map[string]int),
+	}
+	m := suite.fetch(client, NewRetryPolicy(3, time.Millisecond, 10*time.Millisecond))
+	assert.EqualError(suite.T(), m.Error, "giving up after 3 attempts: 503 Service Unavailable")
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, m.Response.StatusCode)
+	assert.Equal(suite.T(), 3, client.attempts["http://example.com"])
+}
+
+func (suite *RetryTestSuite) TestNoRetryWithoutPolicy() {
+	client := &flakyHTTPClient{
+		failures: 1,
+		status:   http.StatusInternalServerError,
+		attempts: make(map[string]int),
+	}
+	m := suite.fetch(client, nil)
+	assert.NoError(suite.T(), m.Error)
+	assert.Equal(suite.T(), http.StatusInternalServerError, m.Response.StatusCode)
+	assert.Equal(suite.T(), 1, client.attempts["http://example.com"])
+
+	// Client errors are not retried either
+	client = &flakyHTTPClient{
+		failures: 1,
+		status:   http.StatusNotFound,
+		attempts: make(map[string]int),
+	}
+	m = suite.fetch(client, NewRetryPolicy(3, time.Millisecond, time.Millisecond))
+	assert.Equal(suite.T(), http.StatusNotFound, m.Response.StatusCode)
+	assert.Equal(suite.T(), 1, client.attempts["http://example.com"])
+}
+
+func (suite *RetryTestSuite) TestExponentialBackoffWithJitter() {
+	p := NewRetryPolicy(10, 100*time.Millisecond, time.Second)
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		d, ok := p.Delay(attempt+1, nil)
+		assert.True(suite.T(), ok)
+		assert.True(suite.T(), d >= max/2 && d <= max, "attempt %d: %v", attempt+1, d)
+	}
+
+	_, ok := p.Delay(10, nil)
+	assert.False(suite.T(), ok)
+
+	var none *RetryPolicy
+	_, ok = none.Delay(1, nil)
+	assert.False(suite.T(), ok)
+}
+
+func (suite *RetryTestSuite) TestRetryAfter() {
+	p := NewRetryPolicy(3, time.Millisecond, time.Minute)
+	res := &Response{Header: http.Header{"Retry-After": {"7"}}}
+	d, ok := p.Delay(1, res)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), 7*time.Second, d)
+
+	// Waiting longer than MaxDelay is not an option
+	res.Header.Set("Retry-After", "120")
+	_, ok = p.Delay(1, res)
+	assert.False(suite.T(), ok)
+
+	now := time.Date(2016, time.October, 1, 12, 0, 0, 0, time.UTC)
+	d, ok = parseRetryAfter("Sat, 01 Oct 2016 12:00:30 GMT", now)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), 30*time.Second, d)
+
+	d, ok = parseRetryAfter("Sat, 01 Oct 2016 11:00:00 GMT", now)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), time.Duration(0), d)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(suite.T(), ok)
+	_, ok = parseRetryAfter("", now)
+	assert.False(suite.T(), ok)
+}
+
+func (suite *RetryTestSuite) TestFailuresAreReported() {
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 2, 2)
+	c.fetcher.client = &flakyHTTPClient{
+		failures: 5,
+		status:   http.StatusBadGateway,
+		attempts: make(map[string]int),
+	}
+	c.SetRetryPolicy(NewRetryPolicy(2, time.Millisecond, time.Millisecond))
+	_, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	report := c.Report()
+	assert.Equal(suite.T(), 1, report.Pages)
+	assert.EqualError(suite.T(), report.Failures["http://example.com"],
+		"giving up after 2 attempts: 502 Bad Gateway")
+	assert.Contains(suite.T(), report.String(), "1 pages could not be fetched")
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
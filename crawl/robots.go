@@ -0,0 +1,410 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antoniou/go-crawler/util"
+)
+
+// DefaultUserAgent is the user-agent token the crawler
+// identifies itself with, unless configured otherwise
+const DefaultUserAgent = "go-crawler"
+
+// DefaultRobotsTimeout is the time a robots.txt file
+// is given to be fetched, before it is given up on
+const DefaultRobotsTimeout = 30 * time.Second
+
+// DefaultRobotsRetryDelay is the time the rules of a robots.txt
+// file that timed out are kept, before it is requested again
+const DefaultRobotsRetryDelay = time.Minute
+
+// robotsMaxBodySize is the size robots.txt files are cut at
+const robotsMaxBodySize = 500 << 10
+
+// RobotsRules holds the rules of a robots.txt file
+// that apply to a specific user-agent
+type RobotsRules struct {
+	rules []robotsRule
+
+	// CrawlDelay is the delay between two requests
+	// the site asks for, or zero if it does not
+	CrawlDelay time.Duration
+
+	// Sitemaps lists the sitemap URLs given in the file
+	Sitemaps []string
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+	delay  time.Duration
+}
+
+// Allowed returns whether path (including the query) may be
+// crawled. The rule with the longest matching pattern decides,
+// and Allow wins over Disallow when both are as long
+func (r *RobotsRules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// ParseRobots parses the robots.txt file read from r and
+// returns the rules applying to userAgent. These are the rules
+// of the groups naming userAgent or, if there are none, those
+// of the groups for any user-agent (*)
+func ParseRobots(r io.Reader, userAgent string) *RobotsRules {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	sitemaps := make([]string, 0)
+
+	// Consecutive User-agent lines start a single group
+	lastWasAgent := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// An empty Disallow allows everything
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{
+					allow:   key == "allow",
+					pattern: value,
+				})
+			}
+		case "crawl-delay":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if current != nil && err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+		lastWasAgent = false
+	}
+
+	rules := &RobotsRules{Sitemaps: sitemaps}
+	token := strings.ToLower(userAgent)
+	if i := strings.Index(token, "/"); i >= 0 {
+		token = token[:i]
+	}
+	if !rules.merge(groups, token) {
+		rules.merge(groups, "*")
+	}
+	return rules
+}
+
+// merge adds the rules of all groups naming agent to r.
+// It returns false if there is no such group
+func (r *RobotsRules) merge(groups []*robotsGroup, agent string) bool {
+	found := false
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a != agent {
+				continue
+			}
+			found = true
+			r.rules = append(r.rules, g.rules...)
+			if g.delay > r.CrawlDelay {
+				r.CrawlDelay = g.delay
+			}
+			break
+		}
+	}
+	return found
+}
+
+// matchRobotsPattern returns whether path matches a robots.txt
+// pattern, in which * matches any sequence of characters and a
+// trailing $ anchors the pattern to the end of the path
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return true
+}
+
+// Robots fetches the robots.txt file of every host it is
+// asked about and caches the rules applying to its user-agent.
+// The files of different hosts are fetched concurrently.
+// A nil *Robots allows every URL
+type Robots struct {
+	client    HTPPClient
+	userAgent string
+	limiter   *HostLimiter
+	logger    Logger
+
+	// header is the User-Agent header
+	// robots.txt files are requested with
+	header string
+
+	// timeout is the time a robots.txt file is given to
+	// be fetched, and retryDelay the time after which one
+	// that timed out is requested again
+	timeout    time.Duration
+	retryDelay time.Duration
+
+	// mutex guards hosts, which holds
+	// the rules of every host, by origin
+	mutex sync.Mutex
+	hosts map[string]*robotsHost
+}
+
+// robotsHost holds the rules of a host, which are set once
+// fetched is closed, and the time they expire at, if they do
+type robotsHost struct {
+	fetched chan struct{}
+	rules   *RobotsRules
+	expires time.Time
+}
+
+// expired returns whether the rules of h have been
+// fetched and are to be fetched again
+func (h *robotsHost) expired() bool {
+	select {
+	case <-h.fetched:
+		return !h.expires.IsZero() && time.Now().After(h.expires)
+	default:
+		return false
+	}
+}
+
+// NewRobots is a Robots constructor. robots.txt files are
+// fetched with client and the rules for userAgent are kept.
+// If limiter is not nil, the requests for robots.txt files
+// wait for it like any other, and the Crawl-delay of each
+// host is passed on to it
+func NewRobots(client HTPPClient, userAgent string, limiter *HostLimiter) *Robots {
+	return &Robots{
+		client:     client,
+		userAgent:  userAgent,
+		limiter:    limiter,
+		logger:     stdLogger{},
+		timeout:    DefaultRobotsTimeout,
+		retryDelay: DefaultRobotsRetryDelay,
+		hosts:      make(map[string]*robotsHost),
+	}
+}
+
+// Allowed returns whether the robots.txt file of
+// the host of u allows u to be crawled
+func (r *Robots) Allowed(u *url.URL) bool {
+	return r.AllowedContext(context.Background(), u)
+}
+
+// AllowedContext is Allowed, cancelling the request
+// for the robots.txt file along with ctx
+func (r *Robots) AllowedContext(ctx context.Context, u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return r.rules(ctx, u).Allowed(path)
+}
+
+// Rules returns the robots.txt rules of the host of u,
+// fetching them the first time the host is seen
+func (r *Robots) Rules(u *url.URL) *RobotsRules {
+	return r.rules(context.Background(), u)
+}
+
+// rules returns the robots.txt rules of the host of u. The
+// first caller asking about a host fetches its file, while
+// the others wait for it, or disallow everything if ctx is
+// done first. A file that timed out is fetched again once
+// retryDelay is over, and one whose fetch was cancelled
+// along with ctx the next time it is asked about
+func (r *Robots) rules(ctx context.Context, u *url.URL) *RobotsRules {
+	key := robotsKey(u)
+
+	r.mutex.Lock()
+	h, ok := r.hosts[key]
+	if !ok || h.expired() {
+		ok = false
+		h = &robotsHost{fetched: make(chan struct{})}
+		r.hosts[key] = h
+	}
+	r.mutex.Unlock()
+	if ok {
+		select {
+		case <-h.fetched:
+			return h.rules
+		case <-ctx.Done():
+			return disallowAll()
+		}
+	}
+
+	rules, err := r.fetch(ctx, key+"/robots.txt")
+	switch {
+	case err != nil && ctx.Err() != nil:
+		r.mutex.Lock()
+		if r.hosts[key] == h {
+			delete(r.hosts, key)
+		}
+		r.mutex.Unlock()
+	case err != nil:
+		h.expires = time.Now().Add(r.retryDelay)
+	}
+	if rules.CrawlDelay > 0 {
+		r.limiter.SetDelay(u.Host, rules.CrawlDelay)
+	}
+	for _, s := range rules.Sitemaps {
+		util.Printf("Robots: %s lists sitemap %s\n", key, s)
+	}
+	h.rules = rules
+	close(h.fetched)
+	return rules
+}
+
+// fetched returns whether the robots.txt file of the
+// host of u has been fetched already, and is not to be
+// fetched again
+func (r *Robots) fetched(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	r.mutex.Lock()
+	h, ok := r.hosts[robotsKey(u)]
+	r.mutex.Unlock()
+	if !ok || h.expired() {
+		return false
+	}
+	select {
+	case <-h.fetched:
+		return true
+	default:
+		return false
+	}
+}
+
+// robotsKey returns the origin of u, which
+// robots.txt files are cached by
+func robotsKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// disallowAll returns the rules of an unreachable
+// robots.txt file, which disallow everything
+func disallowAll() *RobotsRules {
+	return &RobotsRules{
+		rules: []robotsRule{{allow: false, pattern: "/"}},
+	}
+}
+
+// fetch retrieves and parses a robots.txt file, cut at
+// robotsMaxBodySize. A missing file allows everything, while
+// an unreachable one, or one that takes longer than the
+// timeout, disallows everything, as the robots exclusion
+// protocol requires. The error is that of the context when
+// it cut the fetch short, be it ctx or the timeout
+func (r *Robots) fetch(ctx context.Context, robotsURL string) (*RobotsRules, error) {
+	u, err := url.Parse(robotsURL)
+	if err != nil {
+		r.logger.Printf("Could not get %s: %v", robotsURL, err)
+		return disallowAll(), nil
+	}
+	req := NewRequest(u)
+	req.MaxBodySize = robotsMaxBodySize
+	if r.header != "" {
+		req.Header.Set("User-Agent", r.header)
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	release, err := r.limiter.AcquireContext(ctx, u.Host)
+	if err != nil {
+		r.logger.Printf("Could not get %s: %v", robotsURL, err)
+		return disallowAll(), ctx.Err()
+	}
+	res, err := r.client.Do(ctx, req)
+	release()
+	if err != nil {
+		r.logger.Printf("Could not get %s: %v", robotsURL, err)
+		return disallowAll(), ctx.Err()
+	}
+
+	switch {
+	case res.StatusCode >= http.StatusInternalServerError:
+		r.logger.Printf("Could not get %s: %s", robotsURL, res.Status)
+		return disallowAll(), nil
+	case res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest:
+		return &RobotsRules{}, nil
+	}
+	return ParseRobots(bytes.NewReader(res.Body), r.userAgent), nil
+}
@@ -0,0 +1,269 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const testRobots = `
+# Comments are ignored
+User-agent: otherbot
+Disallow: /
+
+User-agent: go-crawler
+User-agent: friendlybot
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search*q=
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /news
+
+Sitemap: http://example.com/sitemap.xml
+`
+
+type RobotsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RobotsTestSuite) TestGroupForUserAgent() {
+	rules := ParseRobots(strings.NewReader(testRobots), "go-crawler/0.1")
+	assert.False(suite.T(), rules.Allowed("/private"))
+	assert.False(suite.T(), rules.Allowed("/private/secret"))
+	assert.True(suite.T(), rules.Allowed("/private/public/page"))
+	assert.True(suite.T(), rules.Allowed("/news"))
+	assert.Equal(suite.T(), 1500*time.Millisecond, rules.CrawlDelay)
+	assert.Equal(suite.T(), []string{"http://example.com/sitemap.xml"}, rules.Sitemaps)
+
+	// Agents sharing a group share its rules
+	rules = ParseRobots(strings.NewReader(testRobots), "FriendlyBot")
+	assert.False(suite.T(), rules.Allowed("/private"))
+}
+
+func (suite *RobotsTestSuite) TestFallbackToAnyUserAgent() {
+	rules := ParseRobots(strings.NewReader(testRobots), "unknownbot")
+	assert.False(suite.T(), rules.Allowed("/news/1"))
+	assert.True(suite.T(), rules.Allowed("/private"))
+	assert.Equal(suite.T(), time.Duration(0), rules.CrawlDelay)
+}
+
+func (suite *RobotsTestSuite) TestWildcardsAndAnchors() {
+	rules := ParseRobots(strings.NewReader(testRobots), "go-crawler")
+	assert.False(suite.T(), rules.Allowed("/docs/file.pdf"))
+	assert.True(suite.T(), rules.Allowed("/docs/file.pdf?download=1"))
+	assert.False(suite.T(), rules.Allowed("/search?lang=en&q=go"))
+	assert.True(suite.T(), rules.Allowed("/search?lang=en"))
+	assert.True(suite.T(), rules.Allowed("/robots.txt"))
+
+	assert.True(suite.T(), matchRobotsPattern("/", "/anything"))
+	assert.True(suite.T(), matchRobotsPattern("/*/b$", "/a/b"))
+	assert.False(suite.T(), matchRobotsPattern("/*/b$", "/a/b/c"))
+	assert.True(suite.T(), matchRobotsPattern("/a*b*c", "/aXbYc/d"))
+	assert.False(suite.T(), matchRobotsPattern("/a*b*c", "/aXcYb"))
+	assert.True(suite.T(), matchRobotsPattern("/exact$", "/exact"))
+	assert.False(suite.T(), matchRobotsPattern("/exact$", "/exactly"))
+}
+
+func (suite *RobotsTestSuite) TestAllowWinsTies() {
+	rules := ParseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /page
+Allow: /page
+Disallow: /
+`), "go-crawler")
+	assert.True(suite.T(), rules.Allowed("/page"))
+	assert.False(suite.T(), rules.Allowed("/other"))
+}
+
+func (suite *RobotsTestSuite) TestRobotsCachePerHost() {
+	client := &countingHTTPClient{
+		siteHTTPClient: siteHTTPClient{pages: map[string]string{
+			"http://example.com/robots.txt": testRobots,
+		}},
+	}
+	limiter := NewHostLimiter(0, 0)
+	r := NewRobots(client, "go-crawler", limiter)
+
+	u, _ := url.ParseRequestURI("http://example.com/private")
+	assert.False(suite.T(), r.Allowed(u))
+	u, _ = url.ParseRequestURI("http://example.com/public")
+	assert.True(suite.T(), r.Allowed(u))
+	assert.Equal(suite.T(), 1, client.count())
+	assert.Equal(suite.T(), 1500*time.Millisecond, limiter.host("example.com").delay)
+
+	// A missing robots.txt allows everything
+	u, _ = url.ParseRequestURI("http://other.com/private")
+	assert.True(suite.T(), r.Allowed(u))
+	assert.Equal(suite.T(), 2, client.count())
+
+	// An unreachable one disallows everything
+	r = NewRobots(&mockHTTPClient{}, "go-crawler", nil)
+	u, _ = url.ParseRequestURI("http://nonexistingwebsite.com/")
+	assert.False(suite.T(), r.Allowed(u))
+}
+
+func (suite *RobotsTestSuite) TestTrackerDropsDisallowedURLs() {
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	pages := map[string]string{
+		"http://example.com/robots.txt": "User-agent: *\nDisallow: /news\n",
+	}
+	for k, v := range testSite {
+		pages[k] = v
+	}
+
+	c := NewTestCrawler(&siteHTTPClient{pages: pages}, 2, 2)
+	c.RespectRobots(DefaultUserAgent)
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"http://example.com/about"},
+		*stmp.LinksFrom("http://example.com"))
+	assert.Equal(suite.T(), 3, c.tracker.enqueued)
+
+	// URLs disallowed are not marked as seen
+	assert.Equal(suite.T(), 3, c.Report().Seen.URLs)
+}
+
+func (suite *RobotsTestSuite) TestRobotsFetchedPerHost() {
+	client := newHeldHTTPClient(map[string]string{
+		"http://example.com/robots.txt": testRobots,
+	}, "http://slow.com/robots.txt")
+	r := NewRobots(client, "go-crawler", nil)
+	defer close(client.release)
+
+	// A host does not wait for the robots.txt of another
+	go r.Rules(&url.URL{Scheme: "http", Host: "slow.com"})
+	<-client.requested
+	u, _ := url.ParseRequestURI("http://example.com/private")
+	assert.False(suite.T(), r.Allowed(u))
+	assert.True(suite.T(), r.fetched(u))
+	assert.False(suite.T(), r.fetched(&url.URL{Scheme: "http", Host: "slow.com"}))
+}
+
+func (suite *RobotsTestSuite) TestRobotsTimeout() {
+	client := newHeldHTTPClient(nil, "http://slow.com/robots.txt")
+	r := NewRobots(client, "go-crawler", nil)
+	r.timeout = 10 * time.Millisecond
+
+	r.retryDelay = 20 * time.Millisecond
+
+	// A robots.txt taking too long disallows everything,
+	// until it is requested again after retryDelay
+	u, _ := url.ParseRequestURI("http://slow.com/page")
+	assert.False(suite.T(), r.Allowed(u))
+	assert.True(suite.T(), r.fetched(u))
+	time.Sleep(30 * time.Millisecond)
+	assert.False(suite.T(), r.fetched(u))
+	r.client = &siteHTTPClient{}
+	assert.True(suite.T(), r.Allowed(u))
+	assert.True(suite.T(), r.fetched(u))
+}
+
+func (suite *RobotsTestSuite) TestRobotsCancelled() {
+	client := newHeldHTTPClient(nil, "http://slow.com/robots.txt")
+	r := NewRobots(client, "go-crawler", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-client.requested
+		cancel()
+	}()
+
+	// A robots.txt whose fetch is cancelled
+	// is fetched again the next time
+	u, _ := url.ParseRequestURI("http://slow.com/page")
+	assert.False(suite.T(), r.AllowedContext(ctx, u))
+	assert.False(suite.T(), r.fetched(u))
+	r.client = &siteHTTPClient{}
+	assert.True(suite.T(), r.Allowed(u))
+}
+
+func (suite *RobotsTestSuite) TestRobotsMaxBodySize() {
+	robots := "User-agent: *\n" + strings.Repeat("# padding\n", 60<<10) + "Disallow: /private\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, robots)
+	}))
+	defer server.Close()
+	r := NewRobots(NewNetHTTPClient(NewHTTPClient()), "go-crawler", nil)
+
+	// The rules past the size robots.txt files are cut at are ignored
+	u, _ := url.ParseRequestURI(server.URL + "/private")
+	assert.True(suite.T(), len(robots) > robotsMaxBodySize)
+	assert.True(suite.T(), r.Allowed(u))
+}
+
+func (suite *RobotsTestSuite) TestRobotsWaitForLimiter() {
+	client := &siteHTTPClient{}
+	limiter := NewHostLimiter(0, 1)
+	r := NewRobots(client, "go-crawler", limiter)
+
+	u, _ := url.ParseRequestURI("http://example.com")
+	release := limiter.Acquire("example.com")
+	fetched := make(chan struct{})
+	go func() {
+		r.Rules(u)
+		close(fetched)
+	}()
+	select {
+	case <-fetched:
+		suite.T().Fatal("robots.txt fetched before the connection was released")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	<-fetched
+}
+
+func (suite *RobotsTestSuite) TestTrackerNotBlockedByRobots() {
+	client := newHeldHTTPClient(map[string]string{
+		"http://example.com":          `<a href="/other">Other</a><a href="/a">A</a>`,
+		"http://example.com/other":    `<a href="http://other.com/private">Private</a>`,
+		"http://example.com/a":        `<a href="/b">B</a>`,
+		"http://other.com/robots.txt": "User-agent: *\nDisallow: /private\n",
+	}, "http://other.com/robots.txt")
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	c := NewTestCrawler(client, 2, 2)
+	c.SetScope(ScopeFunc(func(u *url.URL) bool { return true }))
+	c.RespectRobots(DefaultUserAgent)
+	results := c.Results()
+	crawled := make(chan sitemap.Sitemapper)
+	go func() {
+		stmp, err := c.Crawl(context.Background(), seedURL)
+		assert.NoError(suite.T(), err)
+		crawled <- stmp
+	}()
+
+	// example.com is crawled while the robots.txt of other.com is held
+	<-client.requested
+	timeout := time.After(5 * time.Second)
+	for found := false; !found; {
+		select {
+		case r := <-results:
+			found = r.URL.String() == "http://example.com/b"
+		case <-timeout:
+			suite.T().Fatal("The crawl waited for the robots.txt of other.com")
+		}
+	}
+	close(client.release)
+	go func() {
+		for range results {
+		}
+	}()
+
+	stmp := <-crawled
+	assert.Empty(suite.T(), *stmp.LinksFrom("http://example.com/other"))
+	assert.Equal(suite.T(), c.tracker.enqueued, c.tracker.completed)
+}
+
+func TestRobotsTestSuite(t *testing.T) {
+	suite.Run(t, new(RobotsTestSuite))
+}
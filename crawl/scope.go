@@ -0,0 +1,139 @@
+package crawl
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// A Scope decides which URLs are part of the crawl.
+// The Parser only passes on the links in scope and
+// the Tracker only crawls the URLs in scope
+type Scope interface {
+	// InScope returns whether u is to be crawled
+	InScope(u *url.URL) bool
+}
+
+// ScopeFunc is an adapter to use
+// a function as a Scope
+type ScopeFunc func(u *url.URL) bool
+
+// InScope returns f(u)
+func (f ScopeFunc) InScope(u *url.URL) bool {
+	return f(u)
+}
+
+// DefaultScope is the Scope of a crawl from seed unless
+// configured otherwise: the URLs with the same scheme
+// and host as seed, under the path of seed
+func DefaultScope(seed *url.URL) Scope {
+	return AllScopes(SameScheme(seed), SameHost(seed), PathPrefix(seed))
+}
+
+// AllScopes returns a Scope holding
+// the URLs in all of scopes
+func AllScopes(scopes ...Scope) Scope {
+	return ScopeFunc(func(u *url.URL) bool {
+		for _, s := range scopes {
+			if !s.InScope(u) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// AnyScope returns a Scope holding
+// the URLs in any of scopes
+func AnyScope(scopes ...Scope) Scope {
+	return ScopeFunc(func(u *url.URL) bool {
+		for _, s := range scopes {
+			if s.InScope(u) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// SameScheme returns a Scope holding
+// the URLs with the scheme of seed
+func SameScheme(seed *url.URL) Scope {
+	return ScopeFunc(func(u *url.URL) bool {
+		return strings.EqualFold(u.Scheme, seed.Scheme)
+	})
+}
+
+// SameHost returns a Scope holding the URLs
+// with the host (and port) of seed
+func SameHost(seed *url.URL) Scope {
+	return ScopeFunc(func(u *url.URL) bool {
+		return strings.EqualFold(u.Host, seed.Host)
+	})
+}
+
+// SameDomain returns a Scope holding the URLs whose host is
+// the registrable domain of seed or one of its subdomains.
+// Given http://www.example.co.uk, it holds the URLs of
+// example.co.uk and of any host ending in .example.co.uk.
+// Hosts without a registrable domain, such as IP addresses
+// or localhost, only hold themselves
+func SameDomain(seed *url.URL) Scope {
+	domain := registrableDomain(seed.Hostname())
+	return ScopeFunc(func(u *url.URL) bool {
+		host := strings.ToLower(u.Hostname())
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	})
+}
+
+// registrableDomain returns the public suffix of host plus
+// one label (eTLD+1), or host itself if it has none
+func registrableDomain(host string) string {
+	host = strings.ToLower(host)
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// PathPrefix returns a Scope holding the URLs under the path
+// of seed. The path is matched segment by segment, so that
+// given http://example.com/blog, it holds /blog and /blog/1
+// but not /blogroll
+func PathPrefix(seed *url.URL) Scope {
+	prefix := strings.TrimSuffix(seed.EscapedPath(), "/")
+	return ScopeFunc(func(u *url.URL) bool {
+		path := u.EscapedPath()
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	})
+}
+
+// MatchPatterns returns a Scope holding the URLs that match
+// at least one of include, if there are any, and none of
+// exclude. Patterns are matched against the whole URL
+func MatchPatterns(include, exclude []*regexp.Regexp) Scope {
+	return ScopeFunc(func(u *url.URL) bool {
+		s := u.String()
+		for _, re := range exclude {
+			if re.MatchString(s) {
+				return false
+			}
+		}
+		if len(include) == 0 {
+			return true
+		}
+		for _, re := range include {
+			if re.MatchString(s) {
+				return true
+			}
+		}
+		return false
+	})
+}
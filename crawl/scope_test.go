@@ -0,0 +1,108 @@
+package crawl
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScopeTestSuite struct {
+	suite.Suite
+}
+
+func mustParse(rawurl string) *url.URL {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (suite *ScopeTestSuite) assertScope(s Scope, in, out []string) {
+	for _, u := range in {
+		assert.True(suite.T(), s.InScope(mustParse(u)), "%s should be in scope", u)
+	}
+	for _, u := range out {
+		assert.False(suite.T(), s.InScope(mustParse(u)), "%s should be out of scope", u)
+	}
+}
+
+func (suite *ScopeTestSuite) TestDefaultScope() {
+	suite.assertScope(DefaultScope(mustParse("http://example.com")),
+		[]string{"http://example.com", "http://example.com/about", "http://EXAMPLE.com/"},
+		[]string{
+			"http://example.com.evil.net/",
+			"https://example.com/about",
+			"http://www.example.com/",
+			"http://example.com:8080/",
+		})
+}
+
+func (suite *ScopeTestSuite) TestPathPrefix() {
+	suite.assertScope(PathPrefix(mustParse("http://example.com/blog")),
+		[]string{"http://example.com/blog", "http://example.com/blog/", "http://example.com/blog/1"},
+		[]string{"http://example.com/", "http://example.com/blogroll", "http://example.com/about/blog"})
+
+	suite.assertScope(PathPrefix(mustParse("http://example.com/blog/")),
+		[]string{"http://example.com/blog", "http://example.com/blog/1"},
+		[]string{"http://example.com/blogroll"})
+}
+
+func (suite *ScopeTestSuite) TestSameHostAnyScheme() {
+	suite.assertScope(SameHost(mustParse("http://example.com")),
+		[]string{"http://example.com/about", "https://example.com/about"},
+		[]string{"http://www.example.com/", "http://example.com.evil.net/"})
+}
+
+func (suite *ScopeTestSuite) TestSameDomain() {
+	suite.assertScope(SameDomain(mustParse("http://www.example.co.uk")),
+		[]string{
+			"http://example.co.uk/",
+			"http://www.example.co.uk/",
+			"https://blog.EXAMPLE.co.uk:8080/",
+		},
+		[]string{
+			"http://co.uk/",
+			"http://other.co.uk/",
+			"http://www.example.co.uk.evil.net/",
+			"http://notexample.co.uk/",
+		})
+
+	// Hosts without a registrable domain only hold themselves
+	suite.assertScope(SameDomain(mustParse("http://127.0.0.1:8080")),
+		[]string{"http://127.0.0.1:8080/", "http://127.0.0.1/"},
+		[]string{"http://127.0.0.2/"})
+	suite.assertScope(SameDomain(mustParse("http://localhost")),
+		[]string{"http://localhost/"},
+		[]string{"http://other/"})
+}
+
+func (suite *ScopeTestSuite) TestMatchPatterns() {
+	include := []*regexp.Regexp{regexp.MustCompile(`/blog/`), regexp.MustCompile(`/news/`)}
+	exclude := []*regexp.Regexp{regexp.MustCompile(`\?page=`)}
+
+	suite.assertScope(MatchPatterns(include, exclude),
+		[]string{"http://example.com/blog/1", "http://example.com/news/1"},
+		[]string{"http://example.com/about", "http://example.com/blog/?page=2"})
+
+	suite.assertScope(MatchPatterns(nil, exclude),
+		[]string{"http://example.com/about"},
+		[]string{"http://example.com/?page=2"})
+}
+
+func (suite *ScopeTestSuite) TestAllScopes() {
+	seed := mustParse("http://example.com")
+	s := AllScopes(SameDomain(seed), MatchPatterns(nil, []*regexp.Regexp{regexp.MustCompile(`/private`)}))
+	suite.assertScope(s,
+		[]string{"https://www.example.com/about"},
+		[]string{"http://www.example.com/private", "http://other.com/"})
+
+	assert.True(suite.T(), AllScopes().InScope(seed))
+}
+
+func TestScopeTestSuite(t *testing.T) {
+	suite.Run(t, new(ScopeTestSuite))
+}
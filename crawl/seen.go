@@ -0,0 +1,478 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/willf/bloom"
+)
+
+// A SeenSet holds the URLs seen during a crawl,
+// so that the Tracker crawls every URL once
+type SeenSet interface {
+	// TestAndAdd adds url to the set and
+	// returns whether it was in it already
+	TestAndAdd(url string) bool
+
+	// Stats returns statistics on the set
+	Stats() SeenStats
+}
+
+// A PersistentSeenSet is a SeenSet that can be saved
+// with a checkpoint of the crawl, and loaded back
+// when the crawl is resumed
+type PersistentSeenSet interface {
+	SeenSet
+
+	// Save writes the URLs of the set to w
+	Save(w io.Writer) error
+
+	// Load replaces the URLs of the set
+	// with those saved to r
+	Load(r io.Reader) error
+}
+
+// SeenStats are statistics on a SeenSet
+type SeenStats struct {
+	// URLs is the number of URLs in the set
+	URLs int
+
+	// EstimatedFalsePositiveRate is the probability that
+	// a URL not in the set is reported as seen
+	EstimatedFalsePositiveRate float64
+
+	// FalsePositives is the number of URLs not in the set
+	// that were reported as seen, or -1 if the set cannot
+	// tell. Exact sets correct their false positives
+	FalsePositives int
+}
+
+// ObservedFalsePositiveRate returns the share of the URLs
+// not in the set that were reported as seen, or -1 if the
+// set cannot tell
+func (s SeenStats) ObservedFalsePositiveRate() float64 {
+	if s.FalsePositives < 0 {
+		return -1
+	}
+	if s.URLs+s.FalsePositives == 0 {
+		return 0
+	}
+	return float64(s.FalsePositives) / float64(s.URLs+s.FalsePositives)
+}
+
+func (s SeenStats) String() string {
+	str := fmt.Sprintf("%d URLs seen, estimated false-positive rate %.4f%%",
+		s.URLs, 100*s.EstimatedFalsePositiveRate)
+	if rate := s.ObservedFalsePositiveRate(); rate >= 0 {
+		str += fmt.Sprintf(", observed %.4f%% (%d)", 100*rate, s.FalsePositives)
+	}
+	return str
+}
+
+// HashSeenSet is an exact SeenSet held in memory
+type HashSeenSet struct {
+	urls map[string]struct{}
+}
+
+// NewHashSeenSet is a HashSeenSet constructor
+func NewHashSeenSet() *HashSeenSet {
+	return &HashSeenSet{
+		urls: make(map[string]struct{}),
+	}
+}
+
+// TestAndAdd adds url to the set and
+// returns whether it was in it already
+func (s *HashSeenSet) TestAndAdd(url string) bool {
+	if _, ok := s.urls[url]; ok {
+		return true
+	}
+	s.urls[url] = struct{}{}
+	return false
+}
+
+// Stats returns statistics on the set
+func (s *HashSeenSet) Stats() SeenStats {
+	return SeenStats{URLs: len(s.urls)}
+}
+
+// Save writes the URLs of the set to w, one per line
+func (s *HashSeenSet) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for url := range s.urls {
+		if _, err := fmt.Fprintln(bw, url); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Load replaces the URLs of the set with those saved to r
+func (s *HashSeenSet) Load(r io.Reader) error {
+	urls := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		urls[scanner.Text()] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.urls = urls
+	return nil
+}
+
+// BloomSeenSet is a scalable bloom filter: a SeenSet that
+// adds a bloom filter, twice as large as the previous one,
+// every time the last one is full. The false-positive rate
+// of each filter is half that of the previous one, so that
+// the rate of the whole set stays below the target rate
+// however many URLs are added
+type BloomSeenSet struct {
+	filters []*bloomStage
+	urls    int
+}
+
+// bloomStage is one of the filters of a BloomSeenSet
+type bloomStage struct {
+	filter   *bloom.BloomFilter
+	capacity uint
+	fpRate   float64
+	n        uint
+}
+
+// bloomStageJSON is the representation of
+// a bloomStage saved by a BloomSeenSet
+type bloomStageJSON struct {
+	Filter   *bloom.BloomFilter
+	Capacity uint
+	FPRate   float64
+	N        uint
+}
+
+// NewBloomSeenSet is a BloomSeenSet constructor. capacity is the
+// number of URLs the first filter holds and fpRate the target
+// false-positive rate of the set
+func NewBloomSeenSet(capacity uint, fpRate float64) *BloomSeenSet {
+	s := &BloomSeenSet{}
+	s.grow(capacity, fpRate/2)
+	return s
+}
+
+func (s *BloomSeenSet) grow(capacity uint, fpRate float64) {
+	s.filters = append(s.filters, &bloomStage{
+		filter:   bloom.NewWithEstimates(capacity, fpRate),
+		capacity: capacity,
+		fpRate:   fpRate,
+	})
+}
+
+// TestAndAdd adds url to the set and returns whether it
+// was in it already, or is a false positive
+func (s *BloomSeenSet) TestAndAdd(url string) bool {
+	for _, f := range s.filters {
+		if f.filter.TestString(url) {
+			return true
+		}
+	}
+
+	last := s.filters[len(s.filters)-1]
+	last.filter.AddString(url)
+	last.n++
+	s.urls++
+	if last.n >= last.capacity {
+		s.grow(2*last.capacity, last.fpRate/2)
+	}
+	return false
+}
+
+// Stats returns statistics on the set
+func (s *BloomSeenSet) Stats() SeenStats {
+	// A URL is a false positive if any filter reports it
+	notFalse := 1.0
+	for _, f := range s.filters {
+		notFalse *= 1 - estimateBloomFalsePositiveRate(f.filter, f.n)
+	}
+	return SeenStats{
+		URLs:                       s.urls,
+		EstimatedFalsePositiveRate: 1 - notFalse,
+		FalsePositives:             -1,
+	}
+}
+
+// Save writes the filters of the set to w
+func (s *BloomSeenSet) Save(w io.Writer) error {
+	stages := make([]bloomStageJSON, 0, len(s.filters))
+	for _, f := range s.filters {
+		stages = append(stages, bloomStageJSON{
+			Filter:   f.filter,
+			Capacity: f.capacity,
+			FPRate:   f.fpRate,
+			N:        f.n,
+		})
+	}
+	return json.NewEncoder(w).Encode(stages)
+}
+
+// Load replaces the filters of the set with those saved to r
+func (s *BloomSeenSet) Load(r io.Reader) error {
+	var stages []bloomStageJSON
+	if err := json.NewDecoder(r).Decode(&stages); err != nil {
+		return err
+	}
+	if len(stages) == 0 {
+		return fmt.Errorf("No bloom filter saved")
+	}
+
+	s.filters, s.urls = nil, 0
+	for _, st := range stages {
+		s.filters = append(s.filters, &bloomStage{
+			filter:   st.Filter,
+			capacity: st.Capacity,
+			fpRate:   st.FPRate,
+			n:        st.N,
+		})
+		s.urls += int(st.N)
+	}
+	return nil
+}
+
+// estimateBloomFalsePositiveRate returns the probability
+// of a false positive of f once n elements are added to it
+func estimateBloomFalsePositiveRate(f *bloom.BloomFilter, n uint) float64 {
+	k, m := float64(f.K()), float64(f.Cap())
+	return math.Pow(1-math.Exp(-k*float64(n)/m), k)
+}
+
+// diskBuckets is the number of files the URLs
+// of a DiskSeenSet are spread over
+const diskBuckets = 256
+
+// DiskSeenSet is an exact SeenSet kept on disk, with only a
+// BloomSeenSet in memory. URLs the bloom filter has not seen
+// are new, while those it reports as seen are looked up in
+// the file they are stored in. The URLs are spread over
+// files named after the hash of the URLs they hold, which
+// are kept open until Close
+type DiskSeenSet struct {
+	dir            string
+	files          []*os.File
+	front          *BloomSeenSet
+	urls           int
+	falsePositives int
+
+	// logger is what the files that cannot
+	// be read or written are reported to
+	logger Logger
+}
+
+// NewDiskSeenSet is a DiskSeenSet constructor, keeping URLs
+// in dir. The URLs of an existing set in dir are dropped, for
+// the set to start empty
+func NewDiskSeenSet(dir string) (*DiskSeenSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for i := 0; i < diskBuckets; i++ {
+		if err := os.Remove(bucketPath(dir, i)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return OpenDiskSeenSet(dir)
+}
+
+// OpenDiskSeenSet is a DiskSeenSet constructor, keeping URLs in
+// dir along with those of an existing set in dir, such as the
+// one of a crawl being resumed
+func OpenDiskSeenSet(dir string) (*DiskSeenSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskSeenSet{
+		dir:    dir,
+		files:  make([]*os.File, diskBuckets),
+		logger: stdLogger{},
+	}
+	if err := s.reload(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload fills the bloom filter in memory
+// with the URLs of the files of the set
+func (s *DiskSeenSet) reload() error {
+	s.front = NewBloomSeenSet(10000, 0.01)
+	s.urls, s.falsePositives = 0, 0
+	for i := 0; i < diskBuckets; i++ {
+		err := s.scan(i, func(url string) bool {
+			s.front.TestAndAdd(url)
+			s.urls++
+			return false
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestAndAdd adds url to the set and
+// returns whether it was in it already
+func (s *DiskSeenSet) TestAndAdd(url string) bool {
+	if s.front.TestAndAdd(url) {
+		found := false
+		err := s.scan(s.bucketOf(url), func(u string) bool {
+			found = u == url
+			return found
+		})
+		if err != nil && !os.IsNotExist(err) {
+			s.logger.Printf("Could not read seen URLs: %v", err)
+			return true
+		}
+		if found {
+			return true
+		}
+		s.falsePositives++
+	}
+
+	if err := s.append(url); err != nil {
+		s.logger.Printf("Could not write seen URLs: %v", err)
+	}
+	s.urls++
+	return false
+}
+
+// SetLogger sets the Logger the files of the set that
+// cannot be read or written are reported to. The Tracker
+// sets it to the Logger of the crawl
+func (s *DiskSeenSet) SetLogger(l Logger) {
+	s.logger = l
+}
+
+// Stats returns statistics on the set
+func (s *DiskSeenSet) Stats() SeenStats {
+	return SeenStats{
+		URLs:           s.urls,
+		FalsePositives: s.falsePositives,
+	}
+}
+
+// Save writes the size of the files of the set to w. The
+// URLs themselves are on disk already, and those added
+// after Save are dropped by Load
+func (s *DiskSeenSet) Save(w io.Writer) error {
+	sizes := make([]int64, diskBuckets)
+	for i := range sizes {
+		info, err := os.Stat(s.bucket(i))
+		switch {
+		case err == nil:
+			sizes[i] = info.Size()
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+	return json.NewEncoder(w).Encode(sizes)
+}
+
+// Load brings the set back to the state it was in when
+// saved to r. The set needs to keep its URLs in the
+// same directory as it did then
+func (s *DiskSeenSet) Load(r io.Reader) error {
+	var sizes []int64
+	if err := json.NewDecoder(r).Decode(&sizes); err != nil {
+		return err
+	}
+	if len(sizes) != diskBuckets {
+		return fmt.Errorf("Expected the size of %d files, got %d", diskBuckets, len(sizes))
+	}
+
+	for i, size := range sizes {
+		err := os.Truncate(s.bucket(i), size)
+		if err != nil && !(os.IsNotExist(err) && size == 0) {
+			return err
+		}
+	}
+	return s.reload()
+}
+
+// Close closes the files of the set
+func (s *DiskSeenSet) Close() error {
+	var err error
+	for i, file := range s.files {
+		if file == nil {
+			continue
+		}
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		s.files[i] = nil
+	}
+	return err
+}
+
+func (s *DiskSeenSet) bucket(i int) string {
+	return bucketPath(s.dir, i)
+}
+
+func bucketPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("seen-%02x", i))
+}
+
+func (s *DiskSeenSet) bucketOf(url string) int {
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	return int(h.Sum32() % diskBuckets)
+}
+
+// file returns the open file of bucket i, opening it
+// the first time. A file that does not exist is only
+// created if create is set
+func (s *DiskSeenSet) file(i int, create bool) (*os.File, error) {
+	if s.files[i] != nil {
+		return s.files[i], nil
+	}
+	flag := os.O_RDWR | os.O_APPEND
+	if create {
+		flag |= os.O_CREATE
+	}
+	file, err := os.OpenFile(s.bucket(i), flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.files[i] = file
+	return file, nil
+}
+
+// scan calls f with every URL of bucket i
+// until f returns true
+func (s *DiskSeenSet) scan(i int, f func(url string) bool) error {
+	file, err := s.file(i, false)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(io.NewSectionReader(file, 0, math.MaxInt64))
+	for scanner.Scan() {
+		if f(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *DiskSeenSet) append(url string) error {
+	file, err := s.file(s.bucketOf(url), true)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, url)
+	return err
+}
@@ -0,0 +1,161 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SeenTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *SeenTestSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "seen")
+}
+
+func (suite *SeenTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func seenURL(i int) string {
+	return fmt.Sprintf("http://example.com/page/%d", i)
+}
+
+// assertExact checks that s holds exactly the URLs added to it
+func (suite *SeenTestSuite) assertExact(s SeenSet, urls int) {
+	for i := 0; i < urls; i++ {
+		assert.False(suite.T(), s.TestAndAdd(seenURL(i)))
+	}
+	for i := 0; i < urls; i++ {
+		assert.True(suite.T(), s.TestAndAdd(seenURL(i)))
+	}
+	assert.Equal(suite.T(), urls, s.Stats().URLs)
+	assert.Equal(suite.T(), 0.0, s.Stats().EstimatedFalsePositiveRate)
+}
+
+func (suite *SeenTestSuite) TestHashSeenSet() {
+	s := NewHashSeenSet()
+	suite.assertExact(s, 1000)
+	assert.Equal(suite.T(), 0, s.Stats().FalsePositives)
+}
+
+func (suite *SeenTestSuite) TestBloomSeenSetGrows() {
+	s := NewBloomSeenSet(1000, 0.01)
+	added := 0
+	for i := 0; i < 20000; i++ {
+		if !s.TestAndAdd(seenURL(i)) {
+			added++
+		}
+	}
+	assert.Equal(suite.T(), added, s.Stats().URLs)
+	assert.True(suite.T(), len(s.filters) > 1)
+
+	// No false negatives
+	for i := 0; i < 20000; i++ {
+		assert.True(suite.T(), s.TestAndAdd(seenURL(i)))
+	}
+
+	// The false-positive rate stays below the target rate
+	stats := s.Stats()
+	assert.Equal(suite.T(), -1, stats.FalsePositives)
+	assert.True(suite.T(), stats.EstimatedFalsePositiveRate > 0)
+	assert.True(suite.T(), stats.EstimatedFalsePositiveRate < 0.01, "%v", stats.EstimatedFalsePositiveRate)
+
+	falsePositives := 0
+	for i := 20000; i < 40000; i++ {
+		if s.TestAndAdd(seenURL(i)) {
+			falsePositives++
+		}
+	}
+	assert.True(suite.T(), falsePositives < 200, "%d", falsePositives)
+}
+
+func (suite *SeenTestSuite) TestDiskSeenSet() {
+	s, err := NewDiskSeenSet(suite.dir)
+	assert.NoError(suite.T(), err)
+	suite.assertExact(s, 12000)
+
+	// Its bloom filter has reported some new URLs as seen,
+	// which were then not found on disk
+	assert.True(suite.T(), s.Stats().FalsePositives > 0)
+	assert.True(suite.T(), s.Stats().ObservedFalsePositiveRate() < 0.05)
+
+	assert.NoError(suite.T(), s.Close())
+
+	// The URLs are kept in the directory, for the set to be
+	// opened again, while a new set in the directory is empty
+	s, err = OpenDiskSeenSet(suite.dir)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 12000, s.Stats().URLs)
+	assert.True(suite.T(), s.TestAndAdd(seenURL(42)))
+	assert.False(suite.T(), s.TestAndAdd(seenURL(12000)))
+	assert.NoError(suite.T(), s.Close())
+
+	s, err = NewDiskSeenSet(suite.dir)
+	assert.NoError(suite.T(), err)
+	defer s.Close()
+	assert.Equal(suite.T(), 0, s.Stats().URLs)
+	assert.False(suite.T(), s.TestAndAdd(seenURL(42)))
+	assert.True(suite.T(), s.TestAndAdd(seenURL(42)))
+}
+
+func (suite *SeenTestSuite) TestDiskSeenSetLogger() {
+	s, err := NewDiskSeenSet(suite.dir)
+	assert.NoError(suite.T(), err)
+	logger := &recordingLogger{}
+	NewCrawler(WithSeenSet(s), WithLogger(logger))
+
+	// Files that cannot be written are reported to the crawl's Logger
+	os.RemoveAll(suite.dir)
+	assert.False(suite.T(), s.TestAndAdd(seenURL(1)))
+	assert.Len(suite.T(), logger.lines, 1)
+	assert.Contains(suite.T(), logger.lines[0], "Could not write seen URLs")
+}
+
+func (suite *SeenTestSuite) TestCrawlWithEverySeenSet() {
+	seedURL, _ := url.ParseRequestURI("http://example.com")
+	disk, _ := NewDiskSeenSet(suite.dir)
+	for _, s := range []SeenSet{NewHashSeenSet(), NewBloomSeenSet(2, 0.01), disk} {
+		c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 2, 2)
+		c.SetSeenSet(s)
+		_, err := c.Crawl(context.Background(), seedURL)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 6, c.Report().Pages)
+		assert.Equal(suite.T(), 6, c.Report().Seen.URLs)
+		assert.Contains(suite.T(), c.Report().String(), "6 URLs seen")
+	}
+}
+
+func (suite *SeenTestSuite) TestSaveAndLoad() {
+	disk, _ := NewDiskSeenSet(suite.dir)
+	for _, s := range []PersistentSeenSet{NewHashSeenSet(), NewBloomSeenSet(100, 0.001), disk} {
+		for i := 0; i < 500; i++ {
+			s.TestAndAdd(seenURL(i))
+		}
+		var buf bytes.Buffer
+		assert.NoError(suite.T(), s.Save(&buf))
+
+		// URLs added after Save are forgotten by Load
+		for i := 500; i < 600; i++ {
+			s.TestAndAdd(seenURL(i))
+		}
+		assert.NoError(suite.T(), s.Load(&buf))
+		assert.Equal(suite.T(), 500, s.Stats().URLs)
+		assert.True(suite.T(), s.TestAndAdd(seenURL(0)))
+		assert.True(suite.T(), s.TestAndAdd(seenURL(499)))
+		assert.False(suite.T(), s.TestAndAdd(seenURL(1000)), "%T", s)
+	}
+}
+
+func TestSeenTestSuite(t *testing.T) {
+	suite.Run(t, new(SeenTestSuite))
+}
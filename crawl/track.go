@@ -1,9 +1,13 @@
 package crawl
 
 import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
 	"github.com/antoniou/go-crawler/sitemap"
 	"github.com/antoniou/go-crawler/util"
-	"github.com/willf/bloom"
 )
 
 // A Tracker is an Asynchronous worker interface
@@ -15,6 +19,16 @@ type Tracker interface {
 	// new URL data.
 	SetSitemapper(sitemap.Sitemapper)
 
+	// Seed provides the Tracker with the URLs to
+	// start crawling from. It returns the error of
+	// ctx if ctx is done before the Tracker got them
+	Seed(ctx context.Context, urls ...*url.URL) error
+
+	// Done returns a channel that is closed
+	// once every URL tracked has been fetched
+	// and parsed
+	Done() <-chan struct{}
+
 	// Retrieve Worker
 	Worker() Worker
 }
@@ -26,56 +40,692 @@ type AsyncHttpTracker struct {
 	//Tracker is an Asynchronous Worker
 	*AsyncWorker
 
-	filter     *bloom.BloomFilter
+	seen       SeenSet
 	fetcher    Fetcher
 	parser     Parser
 	sitemapper sitemap.Sitemapper
+	robots     *Robots
+	scope      Scope
+	links      LinkPolicy
+	directives DirectivePolicy
+	priority   PriorityFunc
+
+	canonicalizer *util.Canonicalizer
+	limits        Limits
+	logger        Logger
+
+	seeds      chan []*url.URL
+	interrupts chan struct{}
+	done       chan struct{}
+
+	// grace is the time given to the pages being
+	// crawled to be done once the crawl is interrupted
+	grace time.Duration
+
+	// The crawl is checkpointed to stateDir every
+	// checkpointInterval, if stateDir is set
+	stateDir           string
+	checkpointInterval time.Duration
+
+	// seedURL is the first seed and started
+	// the time the crawl started at
+	seedURL *url.URL
+	started time.Time
+
+	// frontier holds the Requests waiting to be passed to
+	// the Fetcher, by Priority then in the order they were found
+	frontier []*Request
+
+	// inflight holds the Requests passed to the
+	// Fetcher that the Parser is not done with
+	inflight map[string]*Request
+
+	// redirected maps the URLs requested that were
+	// redirected to the page the redirects led to, or to
+	// nil when that page is not to be tracked, until the
+	// Parser is done with them
+	redirected map[string]*url.URL
+
+	// canonicals maps the pages declaring a canonical URL
+	// other than their own to that URL, and statuses the URLs
+	// requested that were not answered with a 200 to their
+	// status code, for the canonicals to be checked once the
+	// crawl is over
+	canonicals map[string]*url.URL
+	statuses   map[string]int
+
+	// held holds the messages of the pages with links waiting
+	// for the robots.txt file of their host, by page requested
+	// and in the order they were received, and heldSeeds the
+	// seeds waiting for theirs. robots.txt files are fetched
+	// off the Tracker loop, robotsFetched receiving the origin
+	// of a file once fetched and fetchingRobots holding the
+	// origins being fetched
+	held           map[string][]*ParseMessage
+	heldSeeds      []*url.URL
+	robotsFetched  chan string
+	fetchingRobots map[string]bool
+
+	// Counters of the work done by the Tracker. A URL is
+	// enqueued when added to the frontier and completed
+	// once the Parser is done with it. The crawl is
+	// over when every URL enqueued has been completed
+	enqueued  int
+	completed int
+	tracked   int
+	bytes     int64
+
+	// limited is set once a limit is reached or the crawl
+	// is interrupted, and no more URLs are to be enqueued
+	limited bool
+
+	report *Report
 }
 
 func NewAsyncHttpTracker(fetcher Fetcher, parser Parser) *AsyncHttpTracker {
-	// FIXME Revisit bloom filter size as future work
-	filter := bloom.New(20000, 5)
 	t := &AsyncHttpTracker{
-		AsyncWorker: &AsyncWorker{
-			Name: "Tracker",
-		},
+		AsyncWorker: NewAsyncWorker("Tracker"),
 
-		filter:  filter,
+		seen:    NewHashSeenSet(),
 		fetcher: fetcher,
 		parser:  parser,
+		seeds:   make(chan []*url.URL),
+		done:    make(chan struct{}),
+		report:  NewReport(),
+
+		interrupts: make(chan struct{}, 1),
+		grace:      DefaultGracePeriod,
+
+		canonicalizer: util.NewCanonicalizer(),
+		logger:        stdLogger{},
+
+		inflight:   make(map[string]*Request),
+		redirected: make(map[string]*url.URL),
+		canonicals: make(map[string]*url.URL),
+		statuses:   make(map[string]int),
+
+		held:           make(map[string][]*ParseMessage),
+		robotsFetched:  make(chan string),
+		fetchingRobots: make(map[string]bool),
 	}
 	t.AsyncWorker.RunFunc = t.Run
 	return t
 }
 
-func (t *AsyncHttpTracker) Run() error {
-	t.Worker().SetState(RUNNING)
+// Run starts the loop that receives URLs from the
+// Parser and hands the frontier over to the Fetcher.
+// Run blocks until ctx is done
+func (t *AsyncHttpTracker) Run(ctx context.Context) error {
+	t.AsyncWorker.SetState(WAITING)
+	return t.AsyncWorker.RunPool(ctx, 1, t.track)
+}
+
+// track is the Tracker loop. The frontier is unbounded,
+// so the Tracker never blocks on the Fetcher while the
+// Fetcher and Parser are blocked on the Tracker
+func (t *AsyncHttpTracker) track(ctx context.Context) {
+	// deadline fires once the crawl has
+	// taken as long as the limits allow
+	var deadline <-chan time.Time
+	if !t.started.IsZero() {
+		deadline = t.deadline()
+	}
+
+	// graceOver fires once the pages being crawled
+	// when the crawl was interrupted are given up
+	var graceOver <-chan time.Time
+
+	var checkpoints <-chan time.Time
+	if t.stateDir != "" && t.checkpointInterval > 0 {
+		ticker := time.NewTicker(t.checkpointInterval)
+		defer ticker.Stop()
+		checkpoints = ticker.C
+	}
+
+	// Seeds held when the crawl was checkpointed
+	// wait for their robots.txt file again
+	if len(t.heldSeeds) > 0 {
+		t.releaseHeld(ctx)
+	}
+
 	for {
-		t.Worker().SetState(WAITING)
+		// Sending on a nil channel blocks, which disables
+		// the request case for as long as the frontier is empty
+		var requests RequestQueue
+		var next *Request
+		if len(t.frontier) > 0 {
+			requests = *t.fetcher.RequestChannel()
+			next = t.frontier[0]
+		}
+
 		select {
+		case seeds := <-t.seeds:
+			if t.started.IsZero() {
+				t.started = time.Now()
+				deadline = t.deadline()
+			}
+			t.seed(ctx, seeds)
 		case res := <-*t.parser.ResponseChannel():
-			t.Worker().SetState(RUNNING)
-			if err := t.handleResponse(res); err != nil {
-				continue
+			t.AsyncWorker.markBusy()
+			t.handleResponse(ctx, res)
+			t.AsyncWorker.markIdle()
+		case requests <- next:
+			util.Printf("Tracker: Passing %s to Fetcher\n", next.URL)
+			t.inflight[next.URL.String()] = next
+			t.frontier[0] = nil
+			t.frontier = t.frontier[1:]
+		case <-deadline:
+			t.limit(t.limits.duration())
+			t.stopEarly()
+		case <-t.interrupts:
+			if !t.report.Interrupted {
+				graceOver = time.After(t.grace)
+				t.interrupt()
 			}
-		case <-t.AsyncWorker.Quit:
-			t.Worker().SetState(STOPPED)
-			return nil
+		case <-graceOver:
+			t.giveUp()
+		case origin := <-t.robotsFetched:
+			delete(t.fetchingRobots, origin)
+			t.releaseHeld(ctx)
+		case <-checkpoints:
+			t.checkpoint()
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func (t *AsyncHttpTracker) handleResponse(m *ParseMessage) error {
+// handleResponse handles m, unless it has to wait for the
+// robots.txt file of the host of its link, in which case it
+// is held along with the messages of its page that follow
+func (t *AsyncHttpTracker) handleResponse(ctx context.Context, m *ParseMessage) {
+	key := m.Request.String()
+	if _, ok := t.held[key]; ok || t.awaitsRobots(ctx, m) {
+		t.held[key] = append(t.held[key], m)
+		return
+	}
+	t.handleMessage(ctx, m)
+}
+
+// releaseHeld handles the messages and seeds held
+// that no longer wait for a robots.txt file
+func (t *AsyncHttpTracker) releaseHeld(ctx context.Context) {
+	for key, held := range t.held {
+		for len(held) > 0 && !t.awaitsRobots(ctx, held[0]) {
+			t.handleMessage(ctx, held[0])
+			held = held[1:]
+		}
+		if len(held) == 0 {
+			delete(t.held, key)
+		} else {
+			t.held[key] = held
+		}
+	}
+	if seeds := t.heldSeeds; len(seeds) > 0 {
+		t.heldSeeds = nil
+		t.seed(ctx, seeds)
+	}
+}
+
+// awaitsRobots returns whether the link in m is to be checked
+// against a robots.txt file that has not been fetched yet
+func (t *AsyncHttpTracker) awaitsRobots(ctx context.Context, m *ParseMessage) bool {
+	if m.Done || len(m.Redirects) > 0 || t.limited || t.links.action(m.Kind) != CrawlLink {
+		return false
+	}
+	return t.inScope(m.Response) && t.awaitRobots(ctx, m.Response)
+}
+
+// awaitRobots returns whether the robots.txt file of the host
+// of u has not been fetched yet, fetching it in the background
+// unless it is already being fetched
+func (t *AsyncHttpTracker) awaitRobots(ctx context.Context, u *url.URL) bool {
+	if t.robots.fetched(u) {
+		return false
+	}
+	origin := robotsKey(u)
+	if !t.fetchingRobots[origin] {
+		t.fetchingRobots[origin] = true
+		go func() {
+			t.robots.rules(ctx, u)
+			select {
+			case t.robotsFetched <- origin:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return true
+}
+
+// handleMessage handles m, a page done with or a link found
+func (t *AsyncHttpTracker) handleMessage(ctx context.Context, m *ParseMessage) {
+	if m.Done {
+		if m.Error != nil {
+			t.report.Failures[m.Request.String()] = m.Error
+		}
+		if page := t.page(m); page != nil {
+			t.addPage(page, m)
+		}
+		delete(t.redirected, m.Request.String())
+		delete(t.inflight, m.Request.String())
+		t.bytes += m.Bytes
+		if limit := t.limits.bytes(t.bytes); limit != "" {
+			t.limit(limit)
+			t.stopEarly()
+		}
+		t.complete()
+		return
+	}
+
+	if len(m.Redirects) > 0 {
+		t.handleRedirects(m)
+		return
+	}
+
+	// Links are found in the page Request was redirected to
+	from := m.Request
+	if page, ok := t.redirected[m.Request.String()]; ok {
+		if page == nil {
+			return
+		}
+		from = page
+	}
+
+	t.tracked++
 	sURL := m.Response.String()
-	if t.filter.TestAndAddString(sURL) {
-		return nil
+	if t.limited {
+		return
+	}
+	switch t.links.action(m.Kind) {
+	case IgnoreLink:
+		return
+	case RecordLink:
+		t.record(from, m)
+		return
+	}
+	if m.Nofollow && t.directives == RespectDirectives {
+		util.Printf("Tracker: Not following %s, marked nofollow\n", sURL)
+		t.record(from, m)
+		return
+	}
+
+	if !t.inScope(m.Response) {
+		util.Printf("Tracker: Dropping %s, out of scope\n", sURL)
+		return
+	}
+
+	if !t.robots.AllowedContext(ctx, m.Response) {
+		util.Printf("Tracker: Dropping %s, disallowed by robots.txt\n", sURL)
+		return
+	}
+
+	// A URL found beyond the maximum depth is not marked as
+	// seen, for it may be found again closer to the seed. The
+	// crawl is bounded by MaxDepth rather than cut short by it
+	if t.limits.depth(m.Depth+1) != "" {
+		util.Printf("Tracker: Not following %s, beyond max depth\n", sURL)
+		t.report.BeyondDepth++
+		return
+	}
+	if t.seen.TestAndAdd(sURL) {
+		return
+	}
+
+	// The pages enqueued are all crawled
+	if limit := t.limits.pages(t.enqueued); limit != "" {
+		t.limit(limit)
+		t.limited = true
+		return
 	}
 
 	util.Printf("Tracker: Adding %s to sitemap\n", sURL)
-	t.sitemapper.Add(m.Request.String(), sURL)
+	t.sitemapper.AddEdge(from.String(), sURL, edgeKind(m.Kind))
+	t.addEdgeDirectives(from, m)
+	req := NewRequest(m.Response)
+	req.Depth = m.Depth + 1
+	req.Referrer = from
+	t.enqueue(req)
+}
+
+// record adds the link in m, found in the page at from, to
+// the sitemap without crawling it. The link is not marked as
+// seen, for it may be crawled when found as a link of another
+// kind or without nofollow
+func (t *AsyncHttpTracker) record(from *url.URL, m *ParseMessage) {
+	to := m.Response.String()
+	if !t.inScope(m.Response) || t.sitemapper.EdgeKind(from.String(), to) != "" {
+		return
+	}
+	util.Printf("Tracker: Recording %s link %s in sitemap\n", m.Kind, to)
+	t.sitemapper.AddEdge(from.String(), to, edgeKind(m.Kind))
+	t.addEdgeDirectives(from, m)
+}
+
+// addEdgeDirectives records that the link in m, found
+// in the page at from, is nofollow, unless directives
+// are ignored
+func (t *AsyncHttpTracker) addEdgeDirectives(from *url.URL, m *ParseMessage) {
+	if m.Nofollow && t.directives != IgnoreDirectives {
+		t.sitemapper.SetEdgeDirectives(from.String(), m.Response.String(),
+			[]sitemap.Directive{sitemap.Nofollow})
+	}
+}
+
+// handleRedirects adds every hop of the redirects from Request
+// to the sitemap. The page the redirects led to is only tracked
+// if it is in scope and has not been seen before, in which case
+// the links found in it are tracked as well. Hops between URLs
+// with the same canonical form, such as /about to /about/ when
+// trailing slashes are removed, lead to the page requested
+func (t *AsyncHttpTracker) handleRedirects(m *ParseMessage) {
+	// The redirects of a request that was pending when the
+	// crawl was checkpointed may have been tracked already
+	if _, ok := t.redirected[m.Request.String()]; ok {
+		return
+	}
+
+	page := t.canonical(m.Request)
+	tracked := true
+	for _, hop := range m.Redirects {
+		from, to := t.canonical(hop.From), t.canonical(hop.To)
+		if from.String() == to.String() {
+			continue
+		}
+		util.Printf("Tracker: Adding redirect %s to sitemap\n", hop)
+		t.sitemapper.AddEdge(from.String(), to.String(), sitemap.RedirectEdge)
+		t.statuses[from.String()] = hop.Status
+		page = to
+		tracked = !t.seen.TestAndAdd(to.String())
+	}
+	if !tracked {
+		page = nil
+	}
+	if page != nil && !t.inScope(page) {
+		util.Printf("Tracker: Not tracking %s, redirected out of scope\n", page)
+		page = nil
+	}
+
+	t.redirected[m.Request.String()] = page
+	t.report.addRedirects(m.Redirects, isRedirectLoop(m.Redirects))
+}
+
+// page returns the page the Parser is done with in m, which
+// is the one Request was redirected to if it was, or nil when
+// that page is not tracked
+func (t *AsyncHttpTracker) page(m *ParseMessage) *url.URL {
+	if to, ok := t.redirected[m.Request.String()]; ok {
+		return to
+	}
+	return m.Request
+}
+
+// addPage records what the Parser found out about page in
+// the sitemap: its Page and, if it could be fetched, the
+// media type of a page that is not HTML, which is a leaf of
+// the sitemap, the robots directives of the page unless they
+// are ignored, and its canonical URL. The status of the page
+// is kept if it is not 200
+func (t *AsyncHttpTracker) addPage(page *url.URL, m *ParseMessage) {
+	t.sitemapper.SetPage(page.String(), m.Page)
+	if m.Error != nil {
+		return
+	}
+	if m.MediaType != "" && !IsHTML(m.MediaType) {
+		t.sitemapper.SetMediaType(page.String(), m.MediaType)
+	}
+	if len(m.Directives) > 0 && t.directives != IgnoreDirectives {
+		t.sitemapper.SetDirectives(page.String(), m.Directives)
+	}
+	if m.Page.Status != 0 && m.Page.Status != http.StatusOK {
+		t.statuses[page.String()] = m.Page.Status
+	}
+	if m.Canonical != nil {
+		t.addCanonical(page, m.Canonical)
+	}
+}
+
+// inScope returns whether u is in the Tracker's
+// Scope. Without a Scope, every URL is
+func (t *AsyncHttpTracker) inScope(u *url.URL) bool {
+	return t.scope == nil || t.scope.InScope(u)
+}
+
+// canonical returns the canonical form of
+// u, or u itself if it has none
+func (t *AsyncHttpTracker) canonical(u *url.URL) *url.URL {
+	if c, err := t.canonicalizer.Canonicalize(u); err == nil {
+		return c
+	}
+	return u
+}
+
+// seed adds seed URLs to the frontier. When robots.txt does
+// not allow any, the crawl is over before it has started
+func (t *AsyncHttpTracker) seed(ctx context.Context, urls []*url.URL) {
+	for _, url := range urls {
+		url = t.canonical(url)
+		if t.seedURL == nil {
+			t.seedURL = url
+			t.sitemapper.SetSeedURL(url.String())
+		}
+		if t.awaitRobots(ctx, url) {
+			t.heldSeeds = append(t.heldSeeds, url)
+			continue
+		}
+		if !t.robots.AllowedContext(ctx, url) {
+			t.logger.Printf("Not crawling %s, disallowed by robots.txt", url)
+			continue
+		}
+		if t.seen.TestAndAdd(url.String()) {
+			continue
+		}
+		t.enqueue(NewRequest(url))
+	}
+	if t.enqueued == t.completed && len(t.heldSeeds) == 0 {
+		t.end()
+	}
+}
+
+// deadline returns a channel receiving the time once the
+// crawl has taken MaxDuration, or nil without a MaxDuration
+func (t *AsyncHttpTracker) deadline() <-chan time.Time {
+	if t.limits.MaxDuration <= 0 {
+		return nil
+	}
+	return time.After(t.limits.MaxDuration - time.Since(t.started))
+}
+
+// enqueue adds req to the frontier, after the Requests of
+// the same or a higher Priority, which the PriorityFunc of
+// the Tracker sets if it has one
+func (t *AsyncHttpTracker) enqueue(req *Request) {
+	if t.priority != nil {
+		req.Priority = t.priority(req)
+	}
+	t.enqueued++
+	i := len(t.frontier)
+	for i > 0 && t.frontier[i-1].Priority < req.Priority {
+		i--
+	}
+	t.frontier = append(t.frontier, nil)
+	copy(t.frontier[i+1:], t.frontier[i:])
+	t.frontier[i] = req
+}
+
+// limit records that limit has left pages out of the crawl
+func (t *AsyncHttpTracker) limit(limit string) {
+	if t.report.Limit == "" {
+		util.Printf("Tracker: Reached %s\n", limit)
+		t.report.Limit = limit
+	}
+}
+
+// interrupt ends the crawl early at the request of the user.
+// The crawl is checkpointed first, for it to be resumed with
+// the URLs dropped
+func (t *AsyncHttpTracker) interrupt() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.logger.Printf("Interrupted, waiting up to %v for the pages being crawled", t.grace)
+	if t.stateDir != "" {
+		t.checkpoint()
+	}
+	t.report.Interrupted = true
+	t.stopEarly()
+}
+
+// cancel ends the crawl once its context is done and the
+// workers have returned. The crawl is checkpointed with the
+// pages being crawled still pending
+func (t *AsyncHttpTracker) cancel() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.end()
+	t.report.Interrupted = true
+}
+
+// giveUp ends an interrupted crawl without waiting
+// any longer for the pages being crawled
+func (t *AsyncHttpTracker) giveUp() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.logger.Printf("Giving up on %d pages being crawled", t.enqueued-t.completed)
+	t.end()
+}
+
+// stopEarly ends the crawl before the frontier is empty. No more
+// URLs are enqueued and those that have not been passed on to
+// the Fetcher yet are dropped. The crawl is over once the pages
+// already passed on are done
+func (t *AsyncHttpTracker) stopEarly() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.limited = true
+	t.heldSeeds = nil
+
+	dropped := len(t.frontier)
+	t.frontier = nil
+	requests := *t.fetcher.RequestChannel()
+	for drained := false; !drained; {
+		select {
+		case req := <-requests:
+			delete(t.inflight, req.URL.String())
+			dropped++
+		default:
+			drained = true
+		}
+	}
+	util.Printf("Tracker: Dropping %d URLs, stopping early\n", dropped)
+
+	t.enqueued -= dropped
+	if t.completed == t.enqueued {
+		t.end()
+	}
+}
+
+// complete records that the Parser is done with a URL
+// and signals the end of the crawl when it was the last
+// one outstanding
+func (t *AsyncHttpTracker) complete() {
+	t.completed++
+	if t.completed == t.enqueued {
+		t.end()
+	}
+}
+
+// end fills in the Report and signals the end of the crawl.
+// Pages given up on may be done after the end, which
+// leaves the Report as it was
+func (t *AsyncHttpTracker) end() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.report.Pages = t.completed
+	t.report.Links = t.tracked
+	t.report.Bytes = t.bytes
+	t.report.Seen = t.seen.Stats()
+	t.report.CanonicalIssues = t.checkCanonicals()
+	util.Printf("Tracker: Crawl done, %d pages crawled, %d links tracked\n",
+		t.completed, t.tracked)
+	// The checkpoint taken when the crawl was interrupted
+	// holds the URLs dropped since, for it to be resumed
+	if t.stateDir != "" && !t.report.Interrupted {
+		t.checkpoint()
+	}
+	t.finish()
+}
+
+// finish closes the Done channel, unless it already is
+func (t *AsyncHttpTracker) finish() {
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+}
+
+// SetSeenSet sets the SeenSet holding the URLs
+// seen, which is a HashSeenSet unless set otherwise
+func (t *AsyncHttpTracker) SetSeenSet(s SeenSet) {
+	t.seen = s
+	t.setSeenLogger()
+}
+
+// setSeenLogger hands the Logger of the Tracker
+// to its SeenSet, if the SeenSet takes one
+func (t *AsyncHttpTracker) setSeenLogger() {
+	if s, ok := t.seen.(interface{ SetLogger(Logger) }); ok {
+		s.SetLogger(t.logger)
+	}
+}
+
+// SetCheckpoint makes the Tracker checkpoint the crawl
+// to dir every interval, and once the crawl is over
+func (t *AsyncHttpTracker) SetCheckpoint(dir string, interval time.Duration) {
+	t.stateDir = dir
+	t.checkpointInterval = interval
+}
+
+// SetGracePeriod sets the time given to the pages being
+// crawled to be done once the crawl is interrupted
+func (t *AsyncHttpTracker) SetGracePeriod(d time.Duration) {
+	t.grace = d
+}
+
+// Interrupt ends the crawl early. No more URLs are passed on
+// to the Fetcher, and the crawl is over once the pages being
+// crawled are done, or once the grace period has passed
+func (t *AsyncHttpTracker) Interrupt() {
+	select {
+	case t.interrupts <- struct{}{}:
+	default:
+	}
+}
+
+// SetLimits sets the Limits of the crawl
+func (t *AsyncHttpTracker) SetLimits(l Limits) {
+	t.limits = l
+}
 
-	go t.fetcher.Fetch(m.Response)
-	return nil
+// SetLogger sets the Logger the Tracker
+// reports how the crawl is going to
+func (t *AsyncHttpTracker) SetLogger(l Logger) {
+	t.logger = l
+	t.setSeenLogger()
 }
 
 // SetSitemapper provides the Tracker with
@@ -86,6 +736,69 @@ func (t *AsyncHttpTracker) SetSitemapper(s sitemap.Sitemapper) {
 	t.sitemapper = s
 }
 
+// SetRobots provides the Tracker with the robots.txt
+// rules that URLs need to pass to be crawled
+func (t *AsyncHttpTracker) SetRobots(r *Robots) {
+	t.robots = r
+}
+
+// SetPriority sets the PriorityFunc giving the Requests
+// their Priority, or leaves them all at 0 if f is nil
+func (t *AsyncHttpTracker) SetPriority(f PriorityFunc) {
+	t.priority = f
+}
+
+// SetScope sets the Scope of the URLs the Tracker
+// crawls. Seeds are crawled regardless of it
+func (t *AsyncHttpTracker) SetScope(s Scope) {
+	t.scope = s
+}
+
+// SetLinkPolicy sets which kinds of links are crawled and
+// which are only recorded in the sitemap. Without one, the
+// DefaultLinkPolicy applies
+func (t *AsyncHttpTracker) SetLinkPolicy(l LinkPolicy) {
+	t.links = l
+}
+
+// SetDirectivePolicy sets what is done with the robots
+// directives of pages and links, which are respected
+// unless set otherwise
+func (t *AsyncHttpTracker) SetDirectivePolicy(d DirectivePolicy) {
+	t.directives = d
+}
+
+// SetCanonicalizer sets the rules seeds and redirects are
+// canonicalized with. They need to be those of the Parser for
+// the Tracker to tell the pages it has seen before
+func (t *AsyncHttpTracker) SetCanonicalizer(c *util.Canonicalizer) {
+	t.canonicalizer = c
+}
+
+// Seed provides the Tracker with the URLs to start crawling
+// from. The Tracker needs to be running. It returns the error
+// of ctx if ctx is done before the Tracker got them
+func (t *AsyncHttpTracker) Seed(ctx context.Context, urls ...*url.URL) error {
+	select {
+	case t.seeds <- urls:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Report returns the Report of the crawl. It is
+// complete once the Done channel is closed
+func (t *AsyncHttpTracker) Report() *Report {
+	return t.report
+}
+
+// Done returns a channel that is closed once every
+// URL tracked has been fetched and parsed
+func (t *AsyncHttpTracker) Done() <-chan struct{} {
+	return t.done
+}
+
 func (t *AsyncHttpTracker) Worker() Worker {
 	return t.AsyncWorker
 }
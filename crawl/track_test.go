@@ -0,0 +1,438 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antoniou/go-crawler/sitemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// siteHTTPClient serves the pages of a fake site, with the
+// headers given for some of them, and a 404 response for
+// any other URL
+type siteHTTPClient struct {
+	pages   map[string]string
+	headers map[string]http.Header
+}
+
+func (c *siteHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	body, ok := c.pages[req.URL.String()]
+	if !ok {
+		return &Response{
+			Status:     "404",
+			StatusCode: http.StatusNotFound,
+		}, nil
+	}
+	return &Response{
+		Status:     "200",
+		StatusCode: http.StatusOK,
+		Header:     c.headers[req.URL.String()],
+		Body:       []byte(body),
+	}, nil
+}
+
+// countingHTTPClient is a siteHTTPClient counting
+// the requests it receives, which can be sent in parallel
+type countingHTTPClient struct {
+	siteHTTPClient
+	requests int32
+}
+
+func (c *countingHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	return c.siteHTTPClient.Do(ctx, req)
+}
+
+// count returns the number of requests received
+func (c *countingHTTPClient) count() int {
+	return int(atomic.LoadInt32(&c.requests))
+}
+
+// heldHTTPClient is a siteHTTPClient that does not answer
+// requests for block until release is closed, or until they
+// are cancelled. requested is closed once block is requested
+type heldHTTPClient struct {
+	siteHTTPClient
+	block     string
+	release   chan struct{}
+	requested chan struct{}
+}
+
+func newHeldHTTPClient(pages map[string]string, block string) *heldHTTPClient {
+	return &heldHTTPClient{
+		siteHTTPClient: siteHTTPClient{pages: pages},
+		block:          block,
+		release:        make(chan struct{}),
+		requested:      make(chan struct{}),
+	}
+}
+
+func (c *heldHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	if req.URL.String() == c.block {
+		close(c.requested)
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return c.siteHTTPClient.Do(ctx, req)
+}
+
+// slowHTTPClient is a siteHTTPClient
+// taking delay to answer every request
+type slowHTTPClient struct {
+	siteHTTPClient
+	delay time.Duration
+}
+
+func (c *slowHTTPClient) Do(ctx context.Context, req *Request) (*Response, error) {
+	time.Sleep(c.delay)
+	return c.siteHTTPClient.Do(ctx, req)
+}
+
+var testSite = map[string]string{
+	"http://example.com": `
+		<a href="/about">About</a>
+		<a href="/news">News</a>
+		<a href="http://example.com">Home</a>`,
+	"http://example.com/about": `
+		<a href="/news">News</a>
+		<a href="/missing">Missing</a>`,
+	"http://example.com/news": `
+		<a href="/news/1">First</a>
+		<a href="/news/2">Second</a>
+		<a href="http://elsewhere.com">Elsewhere</a>`,
+	"http://example.com/news/1": `<a href="/news/2">Second</a>`,
+	"http://example.com/news/2": `<a href="/news/1">First</a>`,
+}
+
+var checkpointSite = map[string]string{
+	"http://example.com": `
+		<a href="/a">A</a>
+		<a href="/b">B</a>`,
+	"http://example.com/a":   `<a href="/a/1">A1</a>`,
+	"http://example.com/a/1": `<a href="/">Home</a>`,
+	"http://example.com/b":   `<a href="/b/1">B1</a>`,
+	"http://example.com/b/1": `<a href="/a">A</a>`,
+}
+
+// NewTestCrawler creates a crawler with
+// fetchers and parsers crawling client
+func NewTestCrawler(client HTPPClient, fetchers, parsers int) *AsyncHTTPCrawler {
+	return NewCrawler(WithClient(client), WithFetchers(fetchers), WithParsers(parsers))
+}
+
+type TrackTestSuite struct {
+	suite.Suite
+	seedURL *url.URL
+}
+
+func (suite *TrackTestSuite) SetupTest() {
+	suite.seedURL, _ = url.ParseRequestURI("http://example.com")
+}
+
+func (suite *TrackTestSuite) TestCrawlEndsWhenFrontierIsEmpty() {
+	for i := 0; i < 20; i++ {
+		c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 4, 2)
+		stmp, err := c.Crawl(context.Background(), suite.seedURL)
+		assert.NoError(suite.T(), err)
+
+		tracker := c.tracker
+		assert.Equal(suite.T(), 6, tracker.enqueued)
+		assert.Equal(suite.T(), tracker.enqueued, tracker.completed)
+		assert.Empty(suite.T(), tracker.frontier)
+
+		assert.ElementsMatch(suite.T(),
+			[]string{"http://example.com/about", "http://example.com/news"},
+			*stmp.LinksFrom("http://example.com"))
+		assert.Len(suite.T(), *stmp.LinksFrom("http://example.com/news"), 2)
+		for _, w := range c.workers {
+			assert.Equal(suite.T(), STOPPED, w.State())
+		}
+	}
+}
+
+func (suite *TrackTestSuite) TestCrawlEndsWhenSeedFails() {
+	c := NewTestCrawler(&mockHTTPClient{}, 1, 1)
+	seedURL, _ := url.ParseRequestURI("http://nonexistingwebsite.com")
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+
+	_, err = stmp.SeedURL()
+	assert.Error(suite.T(), err)
+}
+
+func (suite *TrackTestSuite) TestCrawlFollowsScope() {
+	site := map[string]string{
+		"http://example.com": `
+			<a href="https://example.com/secure">Secure</a>
+			<a href="http://blog.example.com">Blog</a>
+			<a href="http://example.com.evil.net">Evil</a>`,
+		"https://example.com/secure": `<a href="/">Home</a>`,
+		"http://blog.example.com":    `<a href="http://example.com">Home</a>`,
+	}
+
+	// By default, only the seed's scheme and host are crawled
+	c := NewTestCrawler(&siteHTTPClient{pages: site}, 2, 2)
+	_, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, c.tracker.enqueued)
+
+	c = NewTestCrawler(&siteHTTPClient{pages: site}, 2, 2)
+	c.SetScope(SameDomain(suite.seedURL))
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.ElementsMatch(suite.T(),
+		[]string{"https://example.com/secure", "http://blog.example.com"},
+		*stmp.LinksFrom("http://example.com"))
+	assert.Equal(suite.T(), 4, c.tracker.enqueued)
+}
+
+func (suite *TrackTestSuite) TestCanonicalURLsAreCrawledOnce() {
+	site := map[string]string{
+		"http://example.com": `
+			<a href="/about?utm_source=home">About</a>
+			<a href="/about#team">Team</a>
+			<a href="/index.html">Home</a>
+			<a href="/news?b=2&a=1">News</a>`,
+		"http://example.com/about":        `<a href="/news?a=1&b=2&fbclid=x">News</a>`,
+		"http://example.com/news?a=1&b=2": ``,
+	}
+
+	client := &countingHTTPClient{siteHTTPClient: siteHTTPClient{pages: site}}
+	c := NewTestCrawler(client, 1, 1)
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, client.count())
+	assert.ElementsMatch(suite.T(),
+		[]string{"http://example.com/about", "http://example.com/news?a=1&b=2"},
+		*stmp.LinksFrom("http://example.com"))
+	assert.Empty(suite.T(), c.Report().Failures)
+}
+
+func (suite *TrackTestSuite) TestMaxDepth() {
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 4, 2)
+	c.SetLimits(Limits{MaxDepth: 1})
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The crawl is complete within its depth
+	assert.Equal(suite.T(), 3, c.Report().Pages)
+	assert.Equal(suite.T(), "", c.Report().Limit)
+	assert.Equal(suite.T(), "", stmp.Incomplete())
+	assert.Equal(suite.T(), 4, c.Report().BeyondDepth)
+	assert.Contains(suite.T(), c.Report().String(), "4 links beyond the max depth were not followed")
+	assert.Empty(suite.T(), *stmp.LinksFrom("http://example.com/news"))
+}
+
+func (suite *TrackTestSuite) TestMaxPages() {
+	client := &countingHTTPClient{siteHTTPClient: siteHTTPClient{pages: testSite}}
+	c := NewTestCrawler(client, 4, 2)
+	c.SetLimits(Limits{MaxPages: 2})
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), 2, client.count())
+	assert.Equal(suite.T(), 2, c.Report().Pages)
+	assert.Equal(suite.T(), "max pages (2)", c.Report().Limit)
+	assert.Len(suite.T(), *stmp.LinksFrom("http://example.com"), 1)
+}
+
+func (suite *TrackTestSuite) TestMaxBytes() {
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 1, 1)
+	c.SetLimits(Limits{MaxBytes: 1})
+	_, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	report := c.Report()
+	assert.Equal(suite.T(), "max bytes (1)", report.Limit)
+	assert.True(suite.T(), report.Pages < 6)
+	assert.True(suite.T(), report.Bytes >= int64(len(testSite["http://example.com"])))
+	assert.Equal(suite.T(), c.tracker.enqueued, c.tracker.completed)
+}
+
+func (suite *TrackTestSuite) TestMaxDuration() {
+	client := &slowHTTPClient{
+		siteHTTPClient: siteHTTPClient{pages: testSite},
+		delay:          20 * time.Millisecond,
+	}
+	c := NewTestCrawler(client, 1, 1)
+	c.SetLimits(Limits{MaxDuration: 30 * time.Millisecond})
+	start := time.Now()
+	_, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	assert.True(suite.T(), time.Since(start) < 100*time.Millisecond)
+	assert.Equal(suite.T(), "max duration (30ms)", c.Report().Limit)
+	assert.True(suite.T(), c.Report().Pages < 6)
+}
+
+func (suite *TrackTestSuite) TestNewAsyncHttpTrackerConstructor() {
+	f := NewMockFetcher()
+	p := NewAsyncHTTPParser(suite.seedURL, f, DefaultConcurrency)
+	tr := NewAsyncHttpTracker(f, p)
+	tr.SetSitemapper(sitemap.NewGraphSitemap())
+	assert.Implements(suite.T(), (*Tracker)(nil), tr)
+}
+
+func (suite *TrackTestSuite) TestInterrupt() {
+	client := newHeldHTTPClient(checkpointSite, "http://example.com/b")
+	c := NewTestCrawler(client, 2, 2)
+	go func() {
+		<-client.requested
+		c.Interrupt()
+		time.Sleep(10 * time.Millisecond)
+		close(client.release)
+	}()
+	stmp, err := c.Crawl(context.Background(), suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	// The page being crawled is done, but its links are not followed
+	assert.True(suite.T(), c.Report().Interrupted)
+	assert.Equal(suite.T(), "interrupted", stmp.Incomplete())
+	assert.Contains(suite.T(), c.Report().String(), "Interrupted, the sitemap is incomplete")
+	assert.Equal(suite.T(), c.tracker.enqueued, c.tracker.completed)
+	assert.Empty(suite.T(), *stmp.LinksFrom("http://example.com/b"))
+}
+
+func (suite *TrackTestSuite) TestInterruptGracePeriod() {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/slow">Slow</a>`)
+		case "/slow":
+			close(requested)
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewAsyncHTTPCrawler(2, 2)
+	c.SetGracePeriod(20 * time.Millisecond)
+	go func() {
+		<-requested
+		c.Interrupt()
+	}()
+
+	start := time.Now()
+	stmp, err := c.Crawl(context.Background(), seedURL)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), time.Since(start) < 5*time.Second)
+
+	// The slow page is given up on
+	assert.Equal(suite.T(), "interrupted", stmp.Incomplete())
+	assert.Equal(suite.T(), 1, c.Report().Pages)
+	assert.Equal(suite.T(), 2, c.tracker.enqueued)
+}
+
+func (suite *TrackTestSuite) TestCrawlIsCancelled() {
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/slow">Slow</a>`)
+		case "/slow":
+			<-r.Context().Done()
+			close(cancelled)
+		}
+	}))
+	defer server.Close()
+
+	seedURL, _ := url.ParseRequestURI(server.URL)
+	c := NewAsyncHTTPCrawler(2, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	stmp, err := c.Crawl(ctx, seedURL)
+	assert.Equal(suite.T(), context.DeadlineExceeded, err)
+
+	// The request in flight is cancelled too
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		suite.T().Fatal("The request was not cancelled")
+	}
+	assert.Equal(suite.T(), "interrupted", stmp.Incomplete())
+	assert.Equal(suite.T(), []string{server.URL + "/slow"}, *stmp.LinksFrom(server.URL))
+	assert.True(suite.T(), c.Report().Interrupted)
+
+	// A crawl cannot start with a done context
+	_, err = NewAsyncHTTPCrawler(1, 1).Crawl(ctx, seedURL)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *TrackTestSuite) TestCrawlSeveralSeeds() {
+	news, _ := url.ParseRequestURI("http://example.com/news")
+	c := NewTestCrawler(&siteHTTPClient{pages: testSite}, 2, 2)
+	stmp, err := c.Crawl(context.Background(), news, suite.seedURL)
+	assert.NoError(suite.T(), err)
+
+	seed, _ := stmp.SeedURL()
+	assert.Equal(suite.T(), "http://example.com/news", seed)
+	assert.Equal(suite.T(), 6, c.Report().Pages)
+
+	_, err = NewTestCrawler(&siteHTTPClient{pages: testSite}, 1, 1).Crawl(context.Background())
+	assert.Error(suite.T(), err)
+}
+
+func (suite *TrackTestSuite) TestFrontierPriority() {
+	f := NewMockFetcher()
+	tr := NewAsyncHttpTracker(f, NewAsyncHTTPParser(suite.seedURL, f, DefaultConcurrency))
+	for i, priority := range []int{0, 1, 0, 2, 1} {
+		u, _ := url.Parse(fmt.Sprintf("http://example.com/%d", i))
+		req := NewRequest(u)
+		req.Priority = priority
+		tr.enqueue(req)
+	}
+
+	// Higher priorities first, in the order found otherwise
+	order := make([]string, 0, len(tr.frontier))
+	for _, req := range tr.frontier {
+		order = append(order, req.URL.Path)
+	}
+	assert.Equal(suite.T(), []string{"/3", "/1", "/4", "/0", "/2"}, order)
+}
+
+func (suite *TrackTestSuite) TestPriorityFunc() {
+	pages := map[string]string{
+		"http://example.com/a": `<html></html>`,
+		"http://example.com/b": `<html></html>`,
+		"http://example.com/c": `<html></html>`,
+	}
+	c := NewCrawler(WithClient(&siteHTTPClient{pages: pages}), WithFetchers(1), WithParsers(1),
+		WithPriority(func(req *Request) int { return int(req.URL.Path[1]) }))
+	results := c.Results()
+	seeds := make([]*url.URL, 0, len(pages))
+	for _, p := range []string{"a", "b", "c"} {
+		u, _ := url.Parse("http://example.com/" + p)
+		seeds = append(seeds, u)
+	}
+	_, err := c.Crawl(context.Background(), seeds...)
+	assert.NoError(suite.T(), err)
+
+	// The pages are crawled from the highest priority down
+	order := make([]string, 0, len(pages))
+	for r := range results {
+		order = append(order, r.URL.Path)
+	}
+	assert.Equal(suite.T(), []string{"/c", "/b", "/a"}, order)
+}
+
+func TestTrackTestSuite(t *testing.T) {
+	suite.Run(t, new(TrackTestSuite))
+}
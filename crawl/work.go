@@ -1,5 +1,11 @@
 package crawl
 
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
 // Possible Worker states
 const (
 	WAITING uint8 = 0
@@ -11,8 +17,9 @@ const (
 // to manage agents perform work in a different
 // thread.
 type Worker interface {
-	// Run starts the Asynchronous worker
-	Run() error
+	// Run starts the Asynchronous worker. It blocks
+	// until ctx is done and the worker has returned
+	Run(ctx context.Context) error
 
 	// Returns worker name
 	// Example names are:
@@ -33,10 +40,8 @@ type Worker interface {
 // NewAsyncWorker is a constructor for a
 // AsyncWorker.
 func NewAsyncWorker(name string) *AsyncWorker {
-	quit := make(chan uint8)
 	return &AsyncWorker{
 		Name: name,
-		Quit: quit,
 	}
 }
 
@@ -44,34 +49,65 @@ func NewAsyncWorker(name string) *AsyncWorker {
 // It is meant to be embedded in another struct,
 // like AsyncHttpFetcher
 type AsyncWorker struct {
-	RunFunc func() error
+	RunFunc func(ctx context.Context) error
 
-	state uint8
-	Quit  chan uint8
+	state uint32
+	busy  int32
 	Name  string
 }
 
 // Run calls the encapsulating
-func (w *AsyncWorker) Run() error {
-	return w.RunFunc()
+func (w *AsyncWorker) Run(ctx context.Context) error {
+	return w.RunFunc(ctx)
 }
 
-// Stop notifies the quit channel.
-// The encapsulating struct's RunFunc
-// needs to receive from the quit channel
-// in order to stop.
-func (w *AsyncWorker) Stop() {
-	w.Quit <- 0
+// RunPool starts size goroutines executing work and blocks
+// until ctx is done. Every goroutine of the pool is expected
+// to return once ctx is done, and RunPool returns once they
+// all have
+func (w *AsyncWorker) RunPool(ctx context.Context, size int, work func(ctx context.Context)) error {
+	if size < 1 {
+		size = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	w.SetState(STOPPED)
+	wg.Wait()
+	return nil
+}
+
+// markBusy and markIdle keep the state of a pooled
+// worker RUNNING for as long as at least one goroutine
+// of the pool is processing work. A STOPPED worker
+// stays STOPPED
+func (w *AsyncWorker) markBusy() {
+	atomic.AddInt32(&w.busy, 1)
+	atomic.CompareAndSwapUint32(&w.state, uint32(WAITING), uint32(RUNNING))
+}
+
+func (w *AsyncWorker) markIdle() {
+	if atomic.AddInt32(&w.busy, -1) == 0 {
+		atomic.CompareAndSwapUint32(&w.state, uint32(RUNNING), uint32(WAITING))
+	}
 }
 
 // State getter (See interface definition)
 func (w *AsyncWorker) State() uint8 {
-	return w.state
+	return uint8(atomic.LoadUint32(&w.state))
 }
 
 // SetState setter (See interface definition)
 func (w *AsyncWorker) SetState(state uint8) {
-	w.state = state
+	atomic.StoreUint32(&w.state, uint32(state))
 }
 
 // Type returns the Name given to the Worker
@@ -1,6 +1,7 @@
 package crawl
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -19,18 +20,18 @@ type Encapsulator struct {
 	mock.Mock
 }
 
-func (e *Encapsulator) mockedMethod() error {
+func (e *Encapsulator) mockedMethod(ctx context.Context) error {
 	e.Called()
 	return nil
 }
 
-func (e *Encapsulator) mockedMethodReturnsError() error {
+func (e *Encapsulator) mockedMethodReturnsError(ctx context.Context) error {
 	return fmt.Errorf("Mock Error")
 }
 
-func (e *Encapsulator) mockedMethodStopChannel() error {
+func (e *Encapsulator) mockedMethodStopChannel(ctx context.Context) error {
 	select {
-	case <-e.AsyncWorker.Quit:
+	case <-ctx.Done():
 		fmt.Println("HERE")
 		e.Called()
 		return nil
@@ -52,12 +53,12 @@ func NewEncapsulator() *Encapsulator {
 func (suite *WorkTestSuite) TestWorkerCallsEncapsulatorsRunMethod() {
 	e := NewEncapsulator()
 	e.On("mockedMethod").Return(nil)
-	e.AsyncWorker.Run()
+	e.AsyncWorker.Run(context.Background())
 	e.AssertExpectations(suite.T())
 
 	// When mocked method returns error, Run should return error
 	e.AsyncWorker.RunFunc = e.mockedMethodReturnsError
-	assert.Error(suite.T(), e.AsyncWorker.Run())
+	assert.Error(suite.T(), e.AsyncWorker.Run(context.Background()))
 
 }
 
@@ -78,16 +79,48 @@ func (suite *WorkTestSuite) TestWorkerStateIsRight() {
 
 }
 
+func (suite *WorkTestSuite) TestStoppedWorkerStaysStopped() {
+	e := NewEncapsulator()
+	e.markBusy()
+	assert.Equal(suite.T(), RUNNING, e.State())
+	e.markIdle()
+	assert.Equal(suite.T(), WAITING, e.State())
+
+	// A worker stopped while busy is not marked idle or busy again
+	e.markBusy()
+	e.SetState(STOPPED)
+	e.markIdle()
+	assert.Equal(suite.T(), STOPPED, e.State())
+	e.markBusy()
+	assert.Equal(suite.T(), STOPPED, e.State())
+}
+
 // func (suite *WorkTestSuite) TestWorkerStopping() {
 // 	e := NewEncapsulator()
 // 	e.AsyncWorker.RunFunc = e.mockedMethodStopChannel
-// 	e.AsyncWorker.Run()
+// 	ctx, cancel := context.WithCancel(context.Background())
+// 	e.AsyncWorker.Run(ctx)
 // 	e.On("mockedMethodStopChannel").Return(nil)
 //
-// 	e.AsyncWorker.Stop()
+// 	cancel()
 // 	e.AssertExpectations(suite.T())
 // }
 
+// runWorker runs w in the background. stop
+// cancels it and waits until it has returned
+func runWorker(w Worker) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(stopped)
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
 func TestWorkTestSuite(t *testing.T) {
 	suite.Run(t, new(WorkTestSuite))
 }
@@ -1,9 +1,9 @@
 package sitemap
 
 import (
+	"fmt"
 	"io"
-
-	"github.com/willf/bloom"
+	"strings"
 )
 
 // Exporter takes a Sitemapper (Sitemap represenation)
@@ -15,17 +15,32 @@ type Exporter interface {
 // FileExporter exports a Sitemapper to a File
 type FileExporter struct {
 	writer io.WriteCloser
-	filter *bloom.BloomFilter
+
+	// exported holds the nodes whose
+	// links have been exported
+	exported map[string]bool
+
+	// excludeNoindex leaves the noindex pages out
+	excludeNoindex bool
+
+	// fields are the fields of the Pages written
+	fields []PageField
 }
 
-// Export exports Sitemapper s to FileExporter.writer
+// Export exports Sitemapper s to FileExporter.writer.
+// A partial sitemap starts with a note saying why it is
 // Returns nil or error on failure
 func (f *FileExporter) Export(s Sitemapper) error {
 	seedURL, err := s.SeedURL()
 	if err != nil {
 		return err
 	}
-	err = f.exportRecursive(s, seedURL, "")
+	if reason := s.Incomplete(); reason != "" {
+		if _, err := fmt.Fprintf(f.writer, "# Incomplete sitemap: %s\n", reason); err != nil {
+			return err
+		}
+	}
+	err = f.exportRecursive(s, "", seedURL, "")
 	if err != nil {
 		return err
 	}
@@ -33,28 +48,122 @@ func (f *FileExporter) Export(s Sitemapper) error {
 	return f.writer.Close()
 }
 
-func (f *FileExporter) exportRecursive(s Sitemapper, node string, indentation string) error {
-	_, err := f.writer.Write([]byte(indentation + node + "\n"))
-	if err != nil {
-		return err
+// exportRecursive writes node, reached from the node from,
+// followed by the nodes it leads to. Edges other than links,
+// such as redirects, are marked with their kind, and resources
+// that are not HTML pages with their media type. Robots
+// directives are marked as well. A noindex page left out is
+// replaced with the nodes it leads to, and a variant of a
+// canonical page with that page
+func (f *FileExporter) exportRecursive(s Sitemapper, from, node string, indentation string) error {
+	ind := indentation
+	variant := node
+	node = canonicalNode(s, node)
+	directives := s.Directives(node)
+	if !f.excludeNoindex || !HasDirective(directives, Noindex) {
+		line := indentation + node
+		if from != "" {
+			if kind := s.EdgeKind(from, variant); kind != LinkEdge {
+				line += " [" + string(kind) + "]"
+			}
+			if HasDirective(s.EdgeDirectives(from, variant), Nofollow) {
+				line += " [nofollow]"
+			}
+		}
+		if variant != node {
+			line += " [canonical of " + variant + "]"
+		}
+		if mediaType := s.MediaType(node); mediaType != "" {
+			line += " [" + mediaType + "]"
+		}
+		if len(directives) > 0 {
+			line += fmt.Sprintf(" [robots: %s]", joinDirectives(directives))
+		}
+		line += f.pageFields(s, node)
+		_, err := f.writer.Write([]byte(line + "\n"))
+		if err != nil {
+			return err
+		}
+		ind += "  "
 	}
 
-	if !f.filter.TestAndAddString(node) {
-		ind := indentation + "  "
+	if !f.exported[node] {
+		f.exported[node] = true
+		// Variants of the same page are only written once, and
+		// the links of node to its own variants are left out
+		written := make(map[string]bool)
 		links := *s.LinksFrom(node)
 		for _, link := range links {
-			f.exportRecursive(s, link, ind)
+			c := canonicalNode(s, link)
+			if c == node && link != node {
+				continue
+			}
+			if !written[c] {
+				written[c] = true
+				f.exportRecursive(s, node, link, ind)
+			}
 		}
 	}
 
 	return nil
 }
 
+// canonicalNode returns the canonical page node is a
+// variant of, following chains of canonicals, or node
+// itself if it is not a variant
+func canonicalNode(s Sitemapper, node string) string {
+	seen := map[string]bool{node: true}
+	for {
+		c := s.Canonical(node)
+		if c == "" || seen[c] {
+			return node
+		}
+		seen[c] = true
+		node = c
+	}
+}
+
+// pageFields returns the fields of the Page of node that
+// are set among those exported, each marked with its name
+func (f *FileExporter) pageFields(s Sitemapper, node string) string {
+	p, ok := s.Page(node)
+	if !ok {
+		return ""
+	}
+	var fields string
+	for _, field := range f.fields {
+		if v := p.Field(field); v != "" {
+			fields += fmt.Sprintf(" [%s: %s]", field, v)
+		}
+	}
+	return fields
+}
+
+// SetPageFields sets the fields of the Pages
+// written after their URL. None are by default
+func (f *FileExporter) SetPageFields(fields ...PageField) {
+	f.fields = fields
+}
+
+// SetExcludeNoindex sets whether the
+// noindex pages are left out of the export
+func (f *FileExporter) SetExcludeNoindex(exclude bool) {
+	f.excludeNoindex = exclude
+}
+
+// joinDirectives returns directives separated by commas
+func joinDirectives(directives []Directive) string {
+	s := make([]string, 0, len(directives))
+	for _, d := range directives {
+		s = append(s, string(d))
+	}
+	return strings.Join(s, ", ")
+}
+
 // NewExporter is an Exporter constructor
 func NewExporter(w io.WriteCloser) *FileExporter {
-	filter := bloom.New(20000, 5)
 	return &FileExporter{
-		writer: w,
-		filter: filter,
+		writer:   w,
+		exported: make(map[string]bool),
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -54,6 +55,169 @@ func (suite *ExportTestSuite) TestExportValidSitemap() {
 
 }
 
+func (suite *ExportTestSuite) TestExportMarksRedirects() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"old/")
+	s.AddEdge(seedURL+"old/", seedURL+"new/", RedirectEdge)
+
+	mock := new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/old/
+    http://example.com/new/ [redirect]`),
+		strings.TrimSpace(mock.out))
+}
+
+func (suite *ExportTestSuite) TestExportMarksMediaTypes() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"about/")
+	s.Add(seedURL, seedURL+"logo.png")
+	s.SetMediaType(seedURL+"logo.png", "image/png")
+	s.AddEdge(seedURL, seedURL+"style.css", EdgeKind("stylesheet"))
+	s.SetMediaType(seedURL+"style.css", "text/css")
+
+	mock := new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/about/
+  http://example.com/logo.png [image/png]
+  http://example.com/style.css [stylesheet] [text/css]`),
+		strings.TrimSpace(mock.out))
+}
+
+func (suite *ExportTestSuite) TestExportMarksDirectives() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"about/")
+	s.Add(seedURL+"about/", seedURL+"team/")
+	s.Add(seedURL, seedURL+"ads/")
+	s.SetDirectives(seedURL+"about/", []Directive{Noindex, Nofollow})
+	s.SetEdgeDirectives(seedURL, seedURL+"ads/", []Directive{Nofollow})
+
+	mock := new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/about/ [robots: noindex, nofollow]
+    http://example.com/team/
+  http://example.com/ads/ [nofollow]`),
+		strings.TrimSpace(mock.out))
+
+	// noindex pages left out give way to their links
+	mock = new(MockWriter)
+	exp := NewExporter(mock)
+	exp.SetExcludeNoindex(true)
+	exp.Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/team/
+  http://example.com/ads/ [nofollow]`),
+		strings.TrimSpace(mock.out))
+}
+
+func (suite *ExportTestSuite) TestExportCollapsesVariants() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"shoes")
+	s.Add(seedURL, seedURL+"shoes?color=red")
+	s.Add(seedURL, seedURL+"shoes-red")
+	s.Add(seedURL+"shoes", seedURL+"sizes")
+	s.Add(seedURL+"shoes?color=red", seedURL+"colors")
+	s.SetCanonical(seedURL+"shoes?color=red", seedURL+"shoes")
+	s.SetCanonical(seedURL+"shoes-red", seedURL+"shoes?color=red")
+
+	mock := new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	// The links of the variants are those of their canonical page
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/shoes
+    http://example.com/sizes
+    http://example.com/colors`),
+		strings.TrimSpace(mock.out))
+
+	// A variant reached first is replaced with its canonical page
+	s = NewGraphSitemap()
+	s.Add(seedURL, seedURL+"shoes-red")
+	s.Add(seedURL, seedURL+"shoes")
+	s.SetCanonical(seedURL+"shoes-red", seedURL+"shoes")
+
+	mock = new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/shoes [canonical of http://example.com/shoes-red]`),
+		strings.TrimSpace(mock.out))
+
+	// A variant of a page missing from the sitemap stands for itself
+	s = NewGraphSitemap()
+	s.Add(seedURL, seedURL+"shoes-red")
+	s.Add(seedURL+"shoes-red", seedURL+"red")
+	s.SetCanonical(seedURL+"shoes-red", seedURL+"shoes")
+
+	mock = new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/
+  http://example.com/shoes-red
+    http://example.com/red`),
+		strings.TrimSpace(mock.out))
+}
+
+func (suite *ExportTestSuite) TestExportPageFields() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"about/")
+	s.Add(seedURL, seedURL+"missing/")
+	modified := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.SetPage(seedURL, Page{
+		Status:       200,
+		ResponseTime: 120 * time.Millisecond,
+		Title:        `Home "sweet" home`,
+		H2:           []string{"News", "Contact"},
+		LastModified: &modified,
+	})
+	s.SetPage(seedURL+"missing/", Page{Status: 404})
+
+	mock := new(MockWriter)
+	exp := NewExporter(mock)
+	exp.SetPageFields(StatusField, TitleField, H1Field, H2Field, ResponseTimeField, LastModifiedField)
+	exp.Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+http://example.com/ [status: 200] [title: "Home \"sweet\" home"] [h2: "News", "Contact"] [response-time: 120ms] [last-modified: Sun, 01 Mar 2020 12:00:00 GMT]
+  http://example.com/about/
+  http://example.com/missing/ [status: 404]`),
+		strings.TrimSpace(mock.out))
+}
+
+func (suite *ExportTestSuite) TestExportNotesIncompleteSitemap() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"about/")
+	s.SetIncomplete("reached max pages (2)")
+
+	mock := new(MockWriter)
+	NewExporter(mock).Export(s)
+
+	assert.Equal(suite.T(), strings.TrimSpace(`
+# Incomplete sitemap: reached max pages (2)
+http://example.com/
+  http://example.com/about/`),
+		strings.TrimSpace(mock.out))
+}
+
 func (suite *ExportTestSuite) TestFailOnCloseWriter() {
 	seedURL := "http://example.com/"
 	s := NewGraphSitemap()
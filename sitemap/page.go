@@ -0,0 +1,133 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Page is what the crawl found out about the page at a URL
+type Page struct {
+	// Status is the status code of the response, 0 if
+	// the page could not be requested at all
+	Status int `json:"status,omitempty"`
+
+	// ContentType is the media type of the page, and
+	// ContentLength its size in bytes
+	ContentType   string `json:"contentType,omitempty"`
+	ContentLength int64  `json:"contentLength,omitempty"`
+
+	// ResponseTime is the time it took to get the page
+	ResponseTime time.Duration `json:"responseTime,omitempty"`
+
+	// Title, Description, H1 and H2 are the text of the <title>,
+	// the meta description and the <h1> and <h2> of an HTML page
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	H1          []string `json:"h1,omitempty"`
+	H2          []string `json:"h2,omitempty"`
+
+	// Language is the language of the page, as given by
+	// the lang of its <html> or its Content-Language
+	Language string `json:"language,omitempty"`
+
+	// LastModified is the time of the Last-Modified
+	// header, or nil if there was none
+	LastModified *time.Time `json:"lastModified,omitempty"`
+
+	// Error is set if the page could not be fetched
+	Error string `json:"error,omitempty"`
+}
+
+// PageField is a field of a Page an Exporter can emit
+type PageField string
+
+// Possible PageFields
+const (
+	StatusField        PageField = "status"
+	ContentTypeField   PageField = "content-type"
+	ContentLengthField PageField = "content-length"
+	ResponseTimeField  PageField = "response-time"
+	TitleField         PageField = "title"
+	DescriptionField   PageField = "description"
+	H1Field            PageField = "h1"
+	H2Field            PageField = "h2"
+	LanguageField      PageField = "language"
+	LastModifiedField  PageField = "last-modified"
+	ErrorField         PageField = "error"
+)
+
+// PageFields are all the PageFields, in the
+// order exporters emit them in
+var PageFields = []PageField{
+	StatusField, ContentTypeField, ContentLengthField, ResponseTimeField,
+	TitleField, DescriptionField, H1Field, H2Field,
+	LanguageField, LastModifiedField, ErrorField,
+}
+
+// ParsePageField returns the PageField named s
+func ParsePageField(s string) (PageField, error) {
+	for _, f := range PageFields {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown page field %q", s)
+}
+
+// Field returns the value of field f of p, rendered
+// as text, or "" if p does not have one
+func (p Page) Field(f PageField) string {
+	switch f {
+	case StatusField:
+		if p.Status != 0 {
+			return fmt.Sprint(p.Status)
+		}
+	case ContentTypeField:
+		return p.ContentType
+	case ContentLengthField:
+		if p.ContentLength != 0 {
+			return fmt.Sprint(p.ContentLength)
+		}
+	case ResponseTimeField:
+		if p.ResponseTime != 0 {
+			return p.ResponseTime.String()
+		}
+	case TitleField:
+		return quote(p.Title)
+	case DescriptionField:
+		return quote(p.Description)
+	case H1Field:
+		return quoteAll(p.H1)
+	case H2Field:
+		return quoteAll(p.H2)
+	case LanguageField:
+		return p.Language
+	case LastModifiedField:
+		if p.LastModified != nil {
+			return p.LastModified.UTC().Format(http.TimeFormat)
+		}
+	case ErrorField:
+		return quote(p.Error)
+	}
+	return ""
+}
+
+// quote returns s quoted, or "" if s is empty
+func quote(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// quoteAll returns the strings of s quoted and
+// separated by commas, or "" if s is empty
+func quoteAll(s []string) string {
+	quoted := make([]string, 0, len(s))
+	for _, v := range s {
+		quoted = append(quoted, quote(v))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -1,12 +1,40 @@
 package sitemap
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/antoniou/go-crawler/util"
 	"github.com/twmb/algoimpl/go/graph"
 )
 
+// EdgeKind is the kind of relation between two URLs an edge
+// of a sitemap represents. Besides those below, the crawler
+// names edges to resources such as images after their kind
+type EdgeKind string
+
+// Possible EdgeKinds
+const (
+	// LinkEdge: from links to to
+	LinkEdge EdgeKind = "link"
+	// RedirectEdge: from redirects to to
+	RedirectEdge EdgeKind = "redirect"
+)
+
+// Directive is a robots directive, applying
+// either to a page or to a link between pages
+type Directive string
+
+// Possible Directives
+const (
+	// Noindex: the page is not to be indexed
+	Noindex Directive = "noindex"
+	// Nofollow: the link, or the links of
+	// the page, are not to be followed
+	Nofollow Directive = "nofollow"
+)
+
 // A Sitemapper holds the represenation of
 // a sitemap. Links between URLs are created
 // with Add
@@ -14,12 +42,77 @@ type Sitemapper interface {
 	// Add creates a representation of a link
 	Add(from string, to string) error
 
+	// AddEdge creates a representation of
+	// a relation of a specific kind
+	AddEdge(from string, to string, kind EdgeKind) error
+
+	// EdgeKind returns the kind of the
+	// edge between from and to
+	EdgeKind(from string, to string) EdgeKind
+
 	//SeedURL returns the seed URL of the Sitemap
 	// or error in case there is none
 	SeedURL() (string, error)
 
+	// SetSeedURL sets the URL to be the root of the
+	// Sitemap once it links to any other URL
+	SetSeedURL(url string)
+
 	//LinksFrom returns the links from a specific node
 	LinksFrom(URL string) *[]string
+
+	// SetIncomplete marks the sitemap as partial,
+	// giving the reason why it is
+	SetIncomplete(reason string)
+
+	// Incomplete returns the reason why the sitemap
+	// is partial, or "" if it is complete
+	Incomplete() string
+
+	// SetMediaType records the media type of
+	// a URL that is not an HTML page
+	SetMediaType(url string, mediaType string)
+
+	// MediaType returns the media type recorded
+	// for a URL, or "" if it is an HTML page
+	MediaType(url string) string
+
+	// SetDirectives records the robots
+	// directives of the page at url
+	SetDirectives(url string, d []Directive)
+
+	// Directives returns the robots
+	// directives of the page at url
+	Directives(url string) []Directive
+
+	// SetEdgeDirectives records the robots
+	// directives of the link from from to to
+	SetEdgeDirectives(from string, to string, d []Directive)
+
+	// EdgeDirectives returns the robots
+	// directives of the link from from to to
+	EdgeDirectives(from string, to string) []Directive
+
+	// SetCanonical records that the page at url is a
+	// variant of the page at canonical, as declared
+	// by its <link rel="canonical">. The links of the
+	// variant become links of the canonical page. A
+	// canonical URL that is not in the sitemap, or that
+	// leads back to url through the canonicals of other
+	// pages, is not recorded, the variant standing for itself
+	SetCanonical(url string, canonical string)
+
+	// Canonical returns the URL the page at url is a
+	// variant of, or "" if it is not a variant
+	Canonical(url string) string
+
+	// SetPage records what the crawl
+	// found out about the page at url
+	SetPage(url string, p Page)
+
+	// Page returns what the crawl found out about
+	// the page at url, and whether it has been recorded
+	Page(url string) (Page, bool)
 }
 
 // GraphSitemap is a Directed Graph-based
@@ -27,8 +120,33 @@ type Sitemapper interface {
 type GraphSitemap struct {
 	graph    *graph.Graph
 	nodemap  map[string]*graph.Node
+	edges    map[edge]EdgeKind
 	hasNodes bool
 	root     *graph.Node
+	seed     string
+
+	// mediaTypes holds the media types of
+	// the URLs that are not HTML pages
+	mediaTypes map[string]string
+
+	// directives and edgeDirectives hold the robots
+	// directives of the pages and links that have any
+	directives     map[string][]Directive
+	edgeDirectives map[edge][]Directive
+
+	// canonicals maps the pages that are variants
+	// of another page to the URL of that page
+	canonicals map[string]string
+
+	// pages holds the Page of every URL crawled
+	pages map[string]Page
+
+	incomplete string
+}
+
+type edge struct {
+	from string
+	to   string
 }
 
 // NewGraphSitemap constructs a GraphSitemap
@@ -38,9 +156,16 @@ type GraphSitemap struct {
 func NewGraphSitemap() *GraphSitemap {
 	nodemap := make(map[string]*graph.Node)
 	return &GraphSitemap{
-		graph:    graph.New(graph.Directed),
-		nodemap:  nodemap,
-		hasNodes: false,
+		graph:      graph.New(graph.Directed),
+		nodemap:    nodemap,
+		edges:      make(map[edge]EdgeKind),
+		hasNodes:   false,
+		mediaTypes: make(map[string]string),
+
+		directives:     make(map[string][]Directive),
+		edgeDirectives: make(map[edge][]Directive),
+		canonicals:     make(map[string]string),
+		pages:          make(map[string]Page),
 	}
 }
 
@@ -48,14 +173,36 @@ func NewGraphSitemap() *GraphSitemap {
 // GraphSitemap creates a Graph Node for the from and to URLs
 // It also creates an edge for the link between them
 func (s *GraphSitemap) Add(from string, to string) error {
+	return s.AddEdge(from, to, LinkEdge)
+}
+
+// AddEdge creates a representation of a relation of a
+// specific kind. Adding an edge between two URLs that are
+// already linked keeps the kind of the existing edge
+func (s *GraphSitemap) AddEdge(from string, to string, kind EdgeKind) error {
 	nodeFrom, _ := s.addNode(from)
-	if !s.hasNodes {
+	if !s.hasNodes || (from == s.seed && s.root != nodeFrom) {
 		s.makeRoot(nodeFrom)
 	}
 	nodeTo, _ := s.addNode(to)
 
+	e := edge{from: from, to: to}
+	if _, ok := s.edges[e]; !ok {
+		s.edges[e] = kind
+	}
+
 	// Add edge between from and to nodes
-	return s.graph.MakeEdge(*nodeFrom, *nodeTo)
+	if err := s.graph.MakeEdge(*nodeFrom, *nodeTo); err != nil {
+		return err
+	}
+	s.mergeEdge(from, to)
+	return nil
+}
+
+// EdgeKind returns the kind of the edge between from and to,
+// or an empty EdgeKind if there is none
+func (s *GraphSitemap) EdgeKind(from string, to string) EdgeKind {
+	return s.edges[edge{from: from, to: to}]
 }
 
 //SeedURL Returns the seed URL (Root) of the Sitemap
@@ -66,12 +213,27 @@ func (s *GraphSitemap) SeedURL() (string, error) {
 	return (*s.root.Value).(string), nil
 }
 
+// SetSeedURL sets the URL to be the root of the Sitemap.
+// Until it links to any other URL, the root is the first
+// URL that does
+func (s *GraphSitemap) SetSeedURL(url string) {
+	s.seed = url
+	if n, ok := s.nodemap[url]; ok && len(s.graph.Neighbors(*n)) > 0 {
+		s.makeRoot(n)
+	}
+}
+
 //LinksFrom returns the links from a specific node
-// as an unprioritised String slice
+// as an unprioritised String slice, which is empty
+// for URLs that are not in the sitemap
 func (s *GraphSitemap) LinksFrom(url string) *[]string {
 	links := make([]string, 0, 100)
+	n, ok := s.nodemap[url]
+	if !ok {
+		return &links
+	}
 	util.Printf("Neighbors of %s:\n", url)
-	for _, node := range s.graph.Neighbors(*s.nodemap[url]) {
+	for _, node := range s.graph.Neighbors(*n) {
 		val := (*node.Value).(string)
 		util.Printf("%s\n", val)
 		links = append(links, val)
@@ -79,6 +241,233 @@ func (s *GraphSitemap) LinksFrom(url string) *[]string {
 	return &links
 }
 
+// SetIncomplete marks the sitemap as partial,
+// giving the reason why it is
+func (s *GraphSitemap) SetIncomplete(reason string) {
+	s.incomplete = reason
+}
+
+// Incomplete returns the reason why the sitemap
+// is partial, or "" if it is complete
+func (s *GraphSitemap) Incomplete() string {
+	return s.incomplete
+}
+
+// SetMediaType records the media type of
+// a URL that is not an HTML page
+func (s *GraphSitemap) SetMediaType(url string, mediaType string) {
+	s.mediaTypes[url] = mediaType
+}
+
+// MediaType returns the media type recorded
+// for a URL, or "" if it is an HTML page
+func (s *GraphSitemap) MediaType(url string) string {
+	return s.mediaTypes[url]
+}
+
+// SetDirectives records the robots
+// directives of the page at url
+func (s *GraphSitemap) SetDirectives(url string, d []Directive) {
+	if len(d) == 0 {
+		delete(s.directives, url)
+		return
+	}
+	s.directives[url] = d
+}
+
+// Directives returns the robots
+// directives of the page at url
+func (s *GraphSitemap) Directives(url string) []Directive {
+	return s.directives[url]
+}
+
+// SetEdgeDirectives records the robots directives of the
+// link from from to to, and of its copy from the canonical
+// page of from if from is a variant
+func (s *GraphSitemap) SetEdgeDirectives(from string, to string, d []Directive) {
+	e := edge{from: from, to: to}
+	if len(d) == 0 {
+		delete(s.edgeDirectives, e)
+		return
+	}
+	s.edgeDirectives[e] = d
+	if canonical, ok := s.canonicals[from]; ok && s.EdgeKind(canonical, to) != "" {
+		if len(s.EdgeDirectives(canonical, to)) == 0 {
+			s.edgeDirectives[edge{from: canonical, to: to}] = d
+		}
+	}
+}
+
+// EdgeDirectives returns the robots
+// directives of the link from from to to
+func (s *GraphSitemap) EdgeDirectives(from string, to string) []Directive {
+	return s.edgeDirectives[edge{from: from, to: to}]
+}
+
+// SetCanonical records that the page at url is a variant
+// of the page at canonical, merging the links of the
+// variant into those of the canonical page. A canonical
+// URL that is not in the sitemap, or whose chain of
+// canonicals loops back to url, is not recorded
+func (s *GraphSitemap) SetCanonical(url string, canonical string) {
+	if _, ok := s.nodemap[canonical]; !ok || s.leadsTo(canonical, url) {
+		delete(s.canonicals, url)
+		return
+	}
+	s.canonicals[url] = canonical
+	for _, to := range *s.LinksFrom(url) {
+		s.mergeEdge(url, to)
+	}
+}
+
+// leadsTo returns whether following the chain of
+// canonicals from node reaches url, node included
+func (s *GraphSitemap) leadsTo(node string, url string) bool {
+	seen := make(map[string]bool)
+	for node != "" && !seen[node] {
+		if node == url {
+			return true
+		}
+		seen[node] = true
+		node = s.canonicals[node]
+	}
+	return false
+}
+
+// mergeEdge adds the edge from the variant from
+// to to the canonical page of from as well
+func (s *GraphSitemap) mergeEdge(from string, to string) {
+	canonical, ok := s.canonicals[from]
+	if !ok || to == canonical || to == from {
+		return
+	}
+	s.AddEdge(canonical, to, s.EdgeKind(from, to))
+	if d := s.EdgeDirectives(from, to); len(d) > 0 && len(s.EdgeDirectives(canonical, to)) == 0 {
+		s.edgeDirectives[edge{from: canonical, to: to}] = d
+	}
+}
+
+// Canonical returns the URL the page at url is a
+// variant of, or "" if it is not a variant
+func (s *GraphSitemap) Canonical(url string) string {
+	return s.canonicals[url]
+}
+
+// SetPage records what the crawl
+// found out about the page at url
+func (s *GraphSitemap) SetPage(url string, p Page) {
+	s.pages[url] = p
+}
+
+// Page returns what the crawl found out about the
+// page at url, and whether it has been recorded
+func (s *GraphSitemap) Page(url string) (Page, bool) {
+	p, ok := s.pages[url]
+	return p, ok
+}
+
+// HasDirective returns whether d is one of directives
+func HasDirective(directives []Directive, d Directive) bool {
+	for _, directive := range directives {
+		if directive == d {
+			return true
+		}
+	}
+	return false
+}
+
+// graphSitemapJSON is the JSON representation of a GraphSitemap.
+// Edges are listed from the root first, in the order they were
+// added from each URL, for the sitemap to be rebuilt as it was
+type graphSitemapJSON struct {
+	Root       string                 `json:"root"`
+	Incomplete string                 `json:"incomplete,omitempty"`
+	Edges      []edgeJSON             `json:"edges"`
+	MediaTypes map[string]string      `json:"mediaTypes,omitempty"`
+	Directives map[string][]Directive `json:"directives,omitempty"`
+	Canonicals map[string]string      `json:"canonicals,omitempty"`
+	Pages      map[string]Page        `json:"pages,omitempty"`
+}
+
+type edgeJSON struct {
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Kind       EdgeKind    `json:"kind"`
+	Directives []Directive `json:"directives,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, for
+// the sitemap to be saved and restored later
+func (s *GraphSitemap) MarshalJSON() ([]byte, error) {
+	j := graphSitemapJSON{
+		Incomplete: s.incomplete,
+		Edges:      make([]edgeJSON, 0, len(s.edges)),
+		MediaTypes: s.mediaTypes,
+		Directives: s.directives,
+		Canonicals: s.canonicals,
+		Pages:      s.pages,
+	}
+	if s.root != nil {
+		j.Root = (*s.root.Value).(string)
+	}
+
+	urls := make([]string, 0, len(s.nodemap))
+	for u := range s.nodemap {
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, k int) bool {
+		switch {
+		case urls[k] == j.Root:
+			return false
+		case urls[i] == j.Root:
+			return true
+		}
+		return urls[i] < urls[k]
+	})
+
+	for _, from := range urls {
+		for _, node := range s.graph.Neighbors(*s.nodemap[from]) {
+			to := (*node.Value).(string)
+			j.Edges = append(j.Edges, edgeJSON{
+				From:       from,
+				To:         to,
+				Kind:       s.EdgeKind(from, to),
+				Directives: s.EdgeDirectives(from, to),
+			})
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, adding the
+// edges of a saved sitemap to an empty GraphSitemap
+func (s *GraphSitemap) UnmarshalJSON(data []byte) error {
+	var j graphSitemapJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	for _, e := range j.Edges {
+		if err := s.AddEdge(e.From, e.To, e.Kind); err != nil {
+			return err
+		}
+		s.SetEdgeDirectives(e.From, e.To, e.Directives)
+	}
+	for u, mediaType := range j.MediaTypes {
+		s.SetMediaType(u, mediaType)
+	}
+	for u, d := range j.Directives {
+		s.SetDirectives(u, d)
+	}
+	for u, canonical := range j.Canonicals {
+		s.SetCanonical(u, canonical)
+	}
+	for u, p := range j.Pages {
+		s.SetPage(u, p)
+	}
+	s.incomplete = j.Incomplete
+	return nil
+}
+
 func (s *GraphSitemap) makeRoot(root *graph.Node) {
 	util.Printf("Adding ROOT node %s\n", (*root.Value).(string))
 	s.hasNodes = true
@@ -1,8 +1,11 @@
 package sitemap
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -17,6 +20,155 @@ func (suite *SitemapTestSuite) TestStopFetcher() {
 func (suite *SitemapTestSuite) TestNewAsyncHTTPFetcherConstructor() {
 }
 
+func (suite *SitemapTestSuite) TestEdgeKinds() {
+	s := NewGraphSitemap()
+	s.Add("http://example.com/", "http://example.com/old")
+	s.AddEdge("http://example.com/old", "http://example.com/new", RedirectEdge)
+
+	// The kind of an existing edge is kept
+	s.AddEdge("http://example.com/", "http://example.com/old", RedirectEdge)
+
+	assert.Equal(suite.T(), LinkEdge, s.EdgeKind("http://example.com/", "http://example.com/old"))
+	assert.Equal(suite.T(), RedirectEdge, s.EdgeKind("http://example.com/old", "http://example.com/new"))
+	assert.Equal(suite.T(), EdgeKind(""), s.EdgeKind("http://example.com/new", "http://example.com/"))
+	assert.Len(suite.T(), *s.LinksFrom("http://example.com/"), 1)
+}
+
+func (suite *SitemapTestSuite) TestJSONRoundTrip() {
+	s := NewGraphSitemap()
+	s.Add("http://example.com/", "http://example.com/b")
+	s.Add("http://example.com/", "http://example.com/a")
+	s.AddEdge("http://example.com/a", "http://example.com/c", RedirectEdge)
+	s.Add("http://example.com/c", "http://example.com/")
+	s.Add("http://example.com/c", "http://example.com/c.pdf")
+	s.SetMediaType("http://example.com/c.pdf", "application/pdf")
+	s.SetDirectives("http://example.com/c", []Directive{Noindex})
+	s.SetEdgeDirectives("http://example.com/", "http://example.com/b", []Directive{Nofollow})
+	s.SetCanonical("http://example.com/b", "http://example.com/a")
+	modified := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.SetPage("http://example.com/a", Page{
+		Status:       200,
+		ResponseTime: time.Second,
+		Title:        "A",
+		H1:           []string{"A"},
+		LastModified: &modified,
+	})
+	s.SetIncomplete("reached max pages (4)")
+
+	data, err := json.Marshal(s)
+	assert.Nil(suite.T(), err)
+
+	restored := NewGraphSitemap()
+	assert.Nil(suite.T(), json.Unmarshal(data, restored))
+
+	seed, err := restored.SeedURL()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "http://example.com/", seed)
+	assert.Equal(suite.T(), []string{"http://example.com/b", "http://example.com/a"},
+		*restored.LinksFrom("http://example.com/"))
+	assert.Equal(suite.T(), RedirectEdge, restored.EdgeKind("http://example.com/a", "http://example.com/c"))
+	assert.Equal(suite.T(), []string{"http://example.com/", "http://example.com/c.pdf"},
+		*restored.LinksFrom("http://example.com/c"))
+	assert.Equal(suite.T(), "application/pdf", restored.MediaType("http://example.com/c.pdf"))
+	assert.Equal(suite.T(), "", restored.MediaType("http://example.com/c"))
+	assert.Equal(suite.T(), []Directive{Noindex}, restored.Directives("http://example.com/c"))
+	assert.Equal(suite.T(), []Directive{Nofollow},
+		restored.EdgeDirectives("http://example.com/", "http://example.com/b"))
+	assert.Empty(suite.T(), restored.EdgeDirectives("http://example.com/", "http://example.com/a"))
+	assert.Equal(suite.T(), "http://example.com/a", restored.Canonical("http://example.com/b"))
+	assert.Equal(suite.T(), "", restored.Canonical("http://example.com/a"))
+	page, ok := restored.Page("http://example.com/a")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), time.Second, page.ResponseTime)
+	assert.Equal(suite.T(), []string{"A"}, page.H1)
+	assert.True(suite.T(), page.LastModified.Equal(modified))
+	_, ok = restored.Page("http://example.com/b")
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), "reached max pages (4)", restored.Incomplete())
+}
+
+func (suite *SitemapTestSuite) TestSeedURL() {
+	s := NewGraphSitemap()
+	s.SetSeedURL("http://example.com/")
+	_, err := s.SeedURL()
+	assert.Error(suite.T(), err)
+
+	// The seed becomes the root once it links to any other URL
+	s.Add("http://example.com/news", "http://example.com/news/1")
+	seed, _ := s.SeedURL()
+	assert.Equal(suite.T(), "http://example.com/news", seed)
+	s.Add("http://example.com/", "http://example.com/news")
+	seed, _ = s.SeedURL()
+	assert.Equal(suite.T(), "http://example.com/", seed)
+}
+
+func (suite *SitemapTestSuite) TestCanonicals() {
+	s := NewGraphSitemap()
+	s.Add("http://example.com/", "http://example.com/a?v=1")
+	s.Add("http://example.com/", "http://example.com/a")
+	s.Add("http://example.com/a?v=1", "http://example.com/b")
+	s.SetEdgeDirectives("http://example.com/a?v=1", "http://example.com/b", []Directive{Nofollow})
+
+	// The links of a variant are merged into its canonical page's
+	s.SetCanonical("http://example.com/a?v=1", "http://example.com/a")
+	assert.Equal(suite.T(), "http://example.com/a", s.Canonical("http://example.com/a?v=1"))
+	assert.Equal(suite.T(), []string{"http://example.com/b"}, *s.LinksFrom("http://example.com/a"))
+	assert.Equal(suite.T(), []Directive{Nofollow},
+		s.EdgeDirectives("http://example.com/a", "http://example.com/b"))
+	s.Add("http://example.com/a?v=1", "http://example.com/c")
+	assert.Equal(suite.T(), []string{"http://example.com/b", "http://example.com/c"},
+		*s.LinksFrom("http://example.com/a"))
+
+	// Canonical URLs missing from the sitemap are not recorded
+	s.SetCanonical("http://example.com/b", "http://example.com/the-b")
+	assert.Equal(suite.T(), "", s.Canonical("http://example.com/b"))
+	assert.Empty(suite.T(), *s.LinksFrom("http://example.com/the-b"))
+}
+
+func (suite *SitemapTestSuite) TestMutualCanonicals() {
+	s := NewGraphSitemap()
+	s.Add("http://example.com/", "http://example.com/a")
+	s.Add("http://example.com/", "http://example.com/b")
+	s.Add("http://example.com/a", "http://example.com/c")
+	s.Add("http://example.com/b", "http://example.com/d")
+
+	// A canonical leading back to the page is not recorded,
+	// whether directly or through other pages
+	s.SetCanonical("http://example.com/a", "http://example.com/b")
+	s.SetCanonical("http://example.com/b", "http://example.com/a")
+	assert.Equal(suite.T(), "http://example.com/b", s.Canonical("http://example.com/a"))
+	assert.Equal(suite.T(), "", s.Canonical("http://example.com/b"))
+	assert.Equal(suite.T(), []string{"http://example.com/d", "http://example.com/c"},
+		*s.LinksFrom("http://example.com/b"))
+
+	s.SetCanonical("http://example.com/c", "http://example.com/a")
+	s.SetCanonical("http://example.com/b", "http://example.com/c")
+	assert.Equal(suite.T(), "", s.Canonical("http://example.com/b"))
+	s.Add("http://example.com/c", "http://example.com/e")
+	assert.Contains(suite.T(), *s.LinksFrom("http://example.com/b"), "http://example.com/e")
+}
+
+func (suite *SitemapTestSuite) TestPageJSON() {
+	// The fields of a page that are not known are left out
+	data, err := json.Marshal(Page{Status: 200, Title: "A"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), `{"status":200,"title":"A"}`, string(data))
+
+	modified := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	data, err = json.Marshal(Page{Status: 200, LastModified: &modified})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), `{"status":200,"lastModified":"2020-03-01T12:00:00Z"}`, string(data))
+	assert.Equal(suite.T(), "", Page{}.Field(LastModifiedField))
+}
+
+func (suite *SitemapTestSuite) TestParsePageField() {
+	f, err := ParsePageField("last-modified")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), LastModifiedField, f)
+	_, err = ParsePageField("keywords")
+	assert.NotNil(suite.T(), err)
+}
+
 func TestSitemapTestSuite(t *testing.T) {
 	suite.Run(t, new(SitemapTestSuite))
 }
@@ -0,0 +1,116 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// xmlns is the namespace of sitemaps.org sitemaps
+const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// XMLExporter exports a Sitemapper to a File as a
+// sitemaps.org XML sitemap, listing the pages it holds
+type XMLExporter struct {
+	writer io.WriteCloser
+
+	// excludeNoindex leaves the noindex pages out
+	excludeNoindex bool
+}
+
+type xmlURLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	URLs    []xmlURL `xml:"url"`
+}
+
+type xmlURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Export exports Sitemapper s to XMLExporter.writer. The pages
+// are those reached from the seed URL by links and redirects,
+// in the order they are reached, with the canonical page of a
+// variant in its place. Resources that are not HTML pages, and
+// pages answered with a status other than 200, are left out.
+// A partial sitemap starts with a comment saying why it is
+func (x *XMLExporter) Export(s Sitemapper) error {
+	seedURL, err := s.SeedURL()
+	if err != nil {
+		return err
+	}
+	set := xmlURLSet{Xmlns: xmlns, URLs: make([]xmlURL, 0)}
+	listed := make(map[string]bool)
+	visited := map[string]bool{seedURL: true}
+	for queue := []string{seedURL}; len(queue) > 0; queue = queue[1:] {
+		node := canonicalNode(s, queue[0])
+		if !listed[node] && x.lists(s, node) {
+			set.URLs = append(set.URLs, x.url(s, node))
+		}
+		listed[node] = true
+		for _, link := range *s.LinksFrom(queue[0]) {
+			if kind := s.EdgeKind(queue[0], link); kind != LinkEdge && kind != RedirectEdge {
+				continue
+			}
+			if !visited[link] {
+				visited[link] = true
+				queue = append(queue, link)
+			}
+		}
+	}
+
+	if _, err := io.WriteString(x.writer, xml.Header); err != nil {
+		return err
+	}
+	if reason := s.Incomplete(); reason != "" {
+		// "--" is not allowed within XML comments
+		reason = strings.Replace(reason, "--", "- -", -1)
+		if _, err := io.WriteString(x.writer, "<!-- Incomplete sitemap: "+reason+" -->\n"); err != nil {
+			return err
+		}
+	}
+	enc := xml.NewEncoder(x.writer)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(x.writer, "\n"); err != nil {
+		return err
+	}
+	return x.writer.Close()
+}
+
+// lists returns whether node is listed in the sitemap
+func (x *XMLExporter) lists(s Sitemapper, node string) bool {
+	if s.MediaType(node) != "" {
+		return false
+	}
+	if x.excludeNoindex && HasDirective(s.Directives(node), Noindex) {
+		return false
+	}
+	p, ok := s.Page(node)
+	return !ok || p.Status == 200
+}
+
+// url returns the entry of node in the sitemap,
+// with the Last-Modified time of its page if known
+func (x *XMLExporter) url(s Sitemapper, node string) xmlURL {
+	u := xmlURL{Loc: node}
+	if p, ok := s.Page(node); ok && p.LastModified != nil {
+		u.LastMod = p.LastModified.UTC().Format(time.RFC3339)
+	}
+	return u
+}
+
+// SetExcludeNoindex sets whether the
+// noindex pages are left out of the export
+func (x *XMLExporter) SetExcludeNoindex(exclude bool) {
+	x.excludeNoindex = exclude
+}
+
+// NewXMLExporter is an XMLExporter constructor
+func NewXMLExporter(w io.WriteCloser) *XMLExporter {
+	return &XMLExporter{writer: w}
+}
@@ -0,0 +1,104 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type XMLExportTestSuite struct {
+	suite.Suite
+}
+
+// bufferCloser is a bytes.Buffer sitemaps can be exported to
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+func (suite *XMLExportTestSuite) TestExport() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"about")
+	s.Add(seedURL, seedURL+"news")
+	s.AddEdge(seedURL, seedURL+"logo.png", "image")
+	s.AddEdge(seedURL, seedURL+"old", RedirectEdge)
+	s.AddEdge(seedURL+"old", seedURL+"new", RedirectEdge)
+	s.Add(seedURL+"about", seedURL+"report.pdf")
+	s.Add(seedURL+"news", seedURL)
+	s.Add(seedURL+"news", seedURL+"missing")
+	s.SetMediaType(seedURL+"report.pdf", "application/pdf")
+	s.SetPage(seedURL+"old", Page{Status: 301})
+	s.SetPage(seedURL+"missing", Page{Status: 404})
+	modified := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	s.SetPage(seedURL+"about", Page{Status: 200, LastModified: &modified})
+
+	var buf bufferCloser
+	assert.NoError(suite.T(), NewXMLExporter(&buf).Export(s))
+	assert.Equal(suite.T(), `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>http://example.com/</loc>
+  </url>
+  <url>
+    <loc>http://example.com/about</loc>
+    <lastmod>2020-03-01T11:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>http://example.com/news</loc>
+  </url>
+  <url>
+    <loc>http://example.com/new</loc>
+  </url>
+</urlset>
+`, buf.String())
+}
+
+func (suite *XMLExportTestSuite) TestExportExcludesNoindex() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"drafts")
+	s.Add(seedURL+"drafts", seedURL+"drafts/1")
+	s.SetDirectives(seedURL+"drafts", []Directive{Noindex})
+
+	// The links of a noindex page left out are still listed
+	var buf bufferCloser
+	exp := NewXMLExporter(&buf)
+	exp.SetExcludeNoindex(true)
+	assert.NoError(suite.T(), exp.Export(s))
+	assert.NotContains(suite.T(), buf.String(), "<loc>http://example.com/drafts</loc>")
+	assert.Contains(suite.T(), buf.String(), "<loc>http://example.com/drafts/1</loc>")
+
+	buf.Reset()
+	assert.NoError(suite.T(), NewXMLExporter(&buf).Export(s))
+	assert.Contains(suite.T(), buf.String(), "<loc>http://example.com/drafts</loc>")
+}
+
+func (suite *XMLExportTestSuite) TestExportCollapsesVariants() {
+	seedURL := "http://example.com/"
+	s := NewGraphSitemap()
+	s.Add(seedURL, seedURL+"shoes")
+	s.Add(seedURL, seedURL+"shoes?color=red&a=1")
+	s.Add(seedURL+"shoes?color=red&a=1", seedURL+"socks")
+	s.SetCanonical(seedURL+"shoes?color=red&a=1", seedURL+"shoes")
+	s.SetIncomplete("reached max pages (3)")
+
+	var buf bufferCloser
+	assert.NoError(suite.T(), NewXMLExporter(&buf).Export(s))
+	out := buf.String()
+	assert.Contains(suite.T(), out, "<!-- Incomplete sitemap: reached max pages (3) -->\n")
+	assert.Equal(suite.T(), 1, strings.Count(out, "<loc>http://example.com/shoes</loc>"))
+	assert.NotContains(suite.T(), out, "color=red")
+	assert.Contains(suite.T(), out, "<loc>http://example.com/socks</loc>")
+}
+
+func TestXMLExportTestSuite(t *testing.T) {
+	suite.Run(t, new(XMLExportTestSuite))
+}
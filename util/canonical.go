@@ -0,0 +1,235 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultStripParams are the query parameters that track
+// visitors or sessions rather than select content
+var DefaultStripParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid",
+	"jsessionid", "phpsessid", "sessionid", "aspsessionid*",
+}
+
+var (
+	dupSlashes = regexp.MustCompile(`/{2,}`)
+	dirIndex   = regexp.MustCompile(`(?i)(^|/)(?:index\.html?|default\.(?:htm|aspx?))$`)
+)
+
+// Canonicalizer turns URLs into a canonical form, so that two
+// URLs of the same page compare equal. Whatever the rules set,
+// the scheme is lowercased, default ports, dot segments and
+// duplicate slashes are removed, percent-escapes are uppercased
+// and an empty path stands for the root path
+type Canonicalizer struct {
+	// LowercaseHost lowercases the host
+	LowercaseHost bool
+
+	// DecodeEscapes decodes the percent-escapes of
+	// unreserved characters (letters, digits, -._~)
+	DecodeEscapes bool
+
+	// RemoveFragment drops the #fragment
+	RemoveFragment bool
+
+	// SortQuery sorts the query parameters by name
+	SortQuery bool
+
+	// StripParams lists the query parameters dropped, compared
+	// case-insensitively. A trailing * matches any suffix
+	StripParams []string
+
+	// RemoveDirectoryIndex drops index.html, index.htm,
+	// default.htm, default.asp and default.aspx from the
+	// path, other index files being pages of their own
+	RemoveDirectoryIndex bool
+
+	// RemoveTrailingSlash drops the trailing slash of the path.
+	// Sites often redirect such URLs back to the path with the
+	// slash, which costs a request per page
+	RemoveTrailingSlash bool
+}
+
+// NewCanonicalizer returns a Canonicalizer with every
+// rule enabled but RemoveTrailingSlash, stripping
+// the DefaultStripParams
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{
+		LowercaseHost:        true,
+		DecodeEscapes:        true,
+		RemoveFragment:       true,
+		SortQuery:            true,
+		StripParams:          DefaultStripParams,
+		RemoveDirectoryIndex: true,
+	}
+}
+
+// Canonicalize returns the canonical form of orig, which is
+// left untouched. It returns an error if orig is not absolute
+func (c *Canonicalizer) Canonicalize(orig *url.URL) (*url.URL, error) {
+	if orig == nil || !orig.IsAbs() || orig.Host == "" {
+		return nil, fmt.Errorf("Not an absolute URL: %v", orig)
+	}
+
+	var err error
+	u := *orig
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = c.host(u.Scheme, u.Host)
+
+	u.RawPath = c.path(u.EscapedPath())
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return nil, err
+	}
+
+	u.RawQuery = c.query(u.RawQuery)
+	u.ForceQuery = false
+	if c.RemoveFragment {
+		u.Fragment, u.RawFragment = "", ""
+	}
+
+	// Parse the result again, for the fields of the URL
+	// to be what they are for any URL parsed from a page
+	return url.Parse(u.String())
+}
+
+func (c *Canonicalizer) host(scheme, host string) string {
+	if c.LowercaseHost {
+		host = strings.ToLower(host)
+	}
+	host = strings.TrimSuffix(host, ":")
+	switch {
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		host = strings.TrimSuffix(host, ":80")
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+func (c *Canonicalizer) path(path string) string {
+	path = removeDotSegments(dupSlashes.ReplaceAllString(path, "/"))
+	path = c.escapes(path)
+	if c.RemoveDirectoryIndex {
+		path = dirIndex.ReplaceAllString(path, "$1")
+	}
+	if c.RemoveTrailingSlash || path == "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+func (c *Canonicalizer) query(query string) string {
+	params := make([]string, 0)
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !c.strip(p) {
+			params = append(params, c.escapes(p))
+		}
+	}
+	if c.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return paramName(params[i]) < paramName(params[j])
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+// strip returns whether the query parameter p
+// is one of the Canonicalizer's StripParams
+func (c *Canonicalizer) strip(p string) bool {
+	name := paramName(p)
+	if n, err := url.QueryUnescape(name); err == nil {
+		name = n
+	}
+	name = strings.ToLower(name)
+
+	for _, s := range c.StripParams {
+		s = strings.ToLower(s)
+		if strings.HasSuffix(s, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(s, "*")) {
+				return true
+			}
+		} else if name == s {
+			return true
+		}
+	}
+	return false
+}
+
+func paramName(p string) string {
+	if i := strings.Index(p, "="); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
+
+// escapes uppercases the percent-escapes of s and,
+// if DecodeEscapes is set, decodes the escapes of
+// unreserved characters
+func (c *Canonicalizer) escapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		ch := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if c.DecodeEscapes && isUnreserved(ch) {
+			b.WriteByte(ch)
+		} else {
+			b.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments removes the . and .. segments
+// of path, as described in RFC 3986 section 5.2.4
+func removeDotSegments(path string) string {
+	segments := strings.Split(path, "/")
+	res := make([]string, 0, len(segments))
+	for i, s := range segments {
+		switch s {
+		case ".":
+		case "..":
+			if len(res) > 1 {
+				res = res[:len(res)-1]
+			}
+		default:
+			res = append(res, s)
+			continue
+		}
+		// A dot segment at the end leaves a trailing slash
+		if i == len(segments)-1 {
+			res = append(res, "")
+		}
+	}
+	return strings.Join(res, "/")
+}
+
+func isUnreserved(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+		ch == '-' || ch == '.' || ch == '_' || ch == '~'
+}
+
+func isHex(ch byte) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func unhex(ch byte) byte {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	}
+	return ch - 'A' + 10
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func canonicalize(c *Canonicalizer, rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	if u, err = c.Canonicalize(u); err != nil {
+		return err.Error()
+	}
+	return u.String()
+}
+
+func TestCanonicalizeDefaultRules(t *testing.T) {
+	c := NewCanonicalizer()
+	for rawurl, expected := range map[string]string{
+		"HTTP://Example.COM:80/":                        "http://example.com",
+		"https://example.com:443/about":                 "https://example.com/about",
+		"http://example.com:8080/about":                 "http://example.com:8080/about",
+		"http://example.com/a/./b/../c//d":              "http://example.com/a/c/d",
+		"http://example.com/a/b/..":                     "http://example.com/a/",
+		"http://example.com/about#team":                 "http://example.com/about",
+		"http://example.com/?b=2&a=1&b=1":               "http://example.com?a=1&b=2&b=1",
+		"http://example.com/?utm_source=x&UTM_Medium=y": "http://example.com",
+		"http://example.com/?fbclid=1&id=2&gclid=3":     "http://example.com?id=2",
+		"http://example.com/?PHPSESSID=1&q=go":          "http://example.com?q=go",
+		"http://example.com/?ASPSESSIONIDQSTR=1":        "http://example.com",
+		"http://example.com/blog/index.html":            "http://example.com/blog/",
+		"http://example.com/Default.aspx?id=1":          "http://example.com?id=1",
+		"http://example.com/indexes.html":               "http://example.com/indexes.html",
+		"http://example.com/blog/index.htm":             "http://example.com/blog/",
+		"http://example.com/default.asp":                "http://example.com",
+		"http://example.com/feed/index.xml":             "http://example.com/feed/index.xml",
+		"http://example.com/api/index.json":             "http://example.com/api/index.json",
+		"http://example.com/index.php":                  "http://example.com/index.php",
+		"http://example.com/about/":                     "http://example.com/about/",
+		"http://example.com/%7euser/%41%2f%e2%82%ac":    "http://example.com/~user/A%2F%E2%82%AC",
+		"http://example.com/?q=%7e%2f&":                 "http://example.com?q=~%2F",
+		"/about":                                        "Not an absolute URL: /about",
+		"mailto:someone@example.com":                    "Not an absolute URL: mailto:someone@example.com",
+	} {
+		assert.Equal(t, expected, canonicalize(c, rawurl), rawurl)
+	}
+}
+
+func TestCanonicalizeRulesAreConfigurable(t *testing.T) {
+	c := &Canonicalizer{RemoveTrailingSlash: true}
+	for rawurl, expected := range map[string]string{
+		"http://Example.com/about/":          "http://Example.com/about",
+		"http://example.com/":                "http://example.com",
+		"http://example.com/about#team":      "http://example.com/about#team",
+		"http://example.com/?b=2&a=1":        "http://example.com?b=2&a=1",
+		"http://example.com/?utm_source=x":   "http://example.com?utm_source=x",
+		"http://example.com/blog/index.html": "http://example.com/blog/index.html",
+		"http://example.com/%7euser/%2f":     "http://example.com/%7Euser/%2F",
+	} {
+		assert.Equal(t, expected, canonicalize(c, rawurl), rawurl)
+	}
+
+	c = &Canonicalizer{StripParams: []string{"ref", "session_*"}}
+	assert.Equal(t, "http://example.com?id=1",
+		canonicalize(c, "http://example.com/?ref=home&id=1&session_key=2"))
+}
+
+func TestCanonicalizeIsIdempotent(t *testing.T) {
+	c := NewCanonicalizer()
+	c.RemoveTrailingSlash = true
+	for _, rawurl := range []string{
+		"HTTP://Example.COM:80/a/./b/../c//index.html?utm_source=x&b=%7e&a=1#top",
+		"http://example.com/%2F/",
+	} {
+		once := canonicalize(c, rawurl)
+		assert.Equal(t, once, canonicalize(c, once))
+	}
+}
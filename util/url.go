@@ -6,23 +6,22 @@ import (
 	"github.com/goware/urlx"
 )
 
-// NormalizeURL normalizes a url.URL to its canonical form
-// and returns a url.URL
+// canonicalizer holds the rules of NormalizeURL
+var canonicalizer = NewCanonicalizer()
+
+// NormalizeURL normalizes a url.URL to its canonical form, as
+// given by the rules of NewCanonicalizer, and returns a url.URL.
+// orig is left untouched
 func NormalizeURL(orig *url.URL) (*url.URL, error) {
-	normURL, err := urlx.Normalize(orig)
-	if err != nil {
-		return nil, err
-	}
-	u, err := url.ParseRequestURI(normURL)
-	return u, err
+	return canonicalizer.Canonicalize(orig)
 }
 
 // NormalizeStringURL normalizes a String URL to its canonical form
-// and returns a url.URL
+// and returns a url.URL. The scheme defaults to http
 func NormalizeStringURL(orig string) (*url.URL, error) {
-	normURL, err := urlx.NormalizeString(orig)
+	u, err := urlx.Parse(orig)
 	if err != nil {
 		return nil, err
 	}
-	return url.ParseRequestURI(normURL)
+	return NormalizeURL(u)
 }
@@ -28,10 +28,13 @@ func TestNormalizeStringURL(t *testing.T) {
 	assert.Equal(t, url.String(), "http://example.com/about")
 	assert.NoError(t, err)
 
-	// Queries and Fragments currently fail
-	// url, err = NormalizeStringURL("http://example.com:80/about?val=true")
-	// assert.Equal(t, "http://example.com/about", url.String())
-	// assert.NoError(t, err)
+	url, err = NormalizeStringURL("http://example.com:80/about?val=true")
+	assert.Equal(t, "http://example.com/about?val=true", url.String())
+	assert.NoError(t, err)
+
+	url, err = NormalizeStringURL("http://example.com/about?utm_source=x#team")
+	assert.Equal(t, "http://example.com/about", url.String())
+	assert.NoError(t, err)
 
 	// Not implemented
 	url, err = NormalizeStringURL("#")
@@ -60,6 +63,13 @@ func TestNormalizeURL(t *testing.T) {
 	assert.Equal(t, nurl.String(), "http://www.example.com")
 	assert.NoError(t, err)
 
+	// The original URL is left untouched
+	surl, _ = url.ParseRequestURI("HTTP://Example.com:80/about")
+	nurl, err = NormalizeURL(surl)
+	assert.Equal(t, "http://example.com/about", nurl.String())
+	assert.Equal(t, "http://Example.com:80/about", surl.String())
+	assert.NoError(t, err)
+
 	// Relative URL
 	surl, _ = url.ParseRequestURI("/about")
 	nurl, err = NormalizeURL(surl)